@@ -0,0 +1,105 @@
+package bdmv
+
+import (
+	"fmt"
+	"time"
+)
+
+// sourcePacketSize is the size of every packet of an .m2ts file: a
+// 188-byte transport stream packet with a 4-byte timestamp.
+const sourcePacketSize = 192
+
+// Clip is a parsed .clpi file, describing the .m2ts file of the same
+// name.
+type Clip struct {
+	// Name is the 5-digit name of the clip, e.g., "00001".
+	Name string
+	// RecordingRate is the maximum bitrate of the clip, in bytes
+	// per second.
+	RecordingRate uint32
+	SourcePackets uint32
+	// Start and End are the presentation times of the clip.
+	Start   Ticks
+	End     Ticks
+	Streams []Stream
+}
+
+// Size returns the size of the clip's .m2ts file.
+func (c *Clip) Size() int64 {
+	return int64(c.SourcePackets) * sourcePacketSize
+}
+
+// Duration returns the playback time of the whole clip.
+func (c *Clip) Duration() time.Duration {
+	if c.End <= c.Start {
+		return 0
+	}
+	return (c.End - c.Start).Duration()
+}
+
+// ParseClip parses the contents of a .clpi file.
+func ParseClip(name string, b []byte) (*Clip, error) {
+	r := &reader{b: b}
+	r.header("HDMV")
+	sequenceStart := r.u32()
+	programStart := r.u32()
+	r.skip(12) // CPI, ClipMark and ExtensionData start addresses
+	r.skip(12) // reserved
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", name, r.err)
+	}
+	result := &Clip{Name: name}
+
+	r.u32()   // length
+	r.skip(2) // reserved
+	r.skip(2) // Clip_stream_type, application_type
+	r.skip(4) // is_ATC_delta
+	result.RecordingRate = r.u32()
+	result.SourcePackets = r.u32()
+
+	r.seek(sequenceStart)
+	r.u32()   // length
+	r.skip(1) // reserved
+	atcs := int(r.u8())
+	first := true
+	for range atcs {
+		r.skip(4) // SPN_ATC_start
+		stcs := int(r.u8())
+		r.skip(1) // offset_STC_id
+		for range stcs {
+			r.skip(6) // PCR_PID, SPN_STC_start
+			start, end := Ticks(r.u32()), Ticks(r.u32())
+			if first || start < result.Start {
+				result.Start = start
+			}
+			if first || end > result.End {
+				result.End = end
+			}
+			first = false
+		}
+	}
+
+	r.seek(programStart)
+	r.u32()   // length
+	r.skip(1) // reserved
+	programs := int(r.u8())
+	for range programs {
+		r.skip(6) // SPN_program_sequence_start, program_map_PID
+		streams := int(r.u8())
+		r.skip(1) // number_of_groups
+		for range streams {
+			pid := r.u16()
+			info := r.sub(int(r.u8()))
+			stream := info.streamAttributes()
+			stream.PID = pid
+			if r.err == nil {
+				r.err = info.err
+			}
+			result.Streams = append(result.Streams, stream)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", name, r.err)
+	}
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package bdmv
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clipFile returns the contents of a .clpi file for a clip with a
+// single STC sequence.
+func clipFile(packets uint32, start, end Ticks, streams []Stream) []byte {
+	w := &writer{}
+	w.str("HDMV0200")
+	w.zero(32) // addresses and reserved, set below
+	w.block(4, func(w *writer) {
+		w.zero(2)
+		w.u8(1) // Clip_stream_type
+		w.u8(1) // application_type
+		w.zero(4)
+		w.u32(6000000)
+		w.u32(packets)
+		w.zero(128) // TS_type_info_block
+	})
+	w.set32(8, len(w.b))
+	w.block(4, func(w *writer) {
+		w.u8(0)
+		w.u8(1) // number_of_ATC_sequences
+		w.u32(0)
+		w.u8(1) // number_of_STC_sequences
+		w.u8(0)
+		w.u16(0x1001)
+		w.u32(0)
+		w.u32(uint32(start))
+		w.u32(uint32(end))
+	})
+	w.set32(12, len(w.b))
+	w.block(4, func(w *writer) {
+		w.u8(0)
+		w.u8(1) // number_of_programs
+		w.u32(0)
+		w.u16(0x100)
+		w.u8(uint8(len(streams)))
+		w.u8(0)
+		for _, s := range streams {
+			w.u16(s.PID)
+			w.attributes(s)
+		}
+	})
+	return w.b
+}
+
+func TestParseClip(t *testing.T) {
+	streams := []Stream{
+		{PID: 0x1011, CodingType: 0x24},
+		{PID: 0x1100, CodingType: 0x83, LangCode: "jpn"},
+		{PID: 0x1200, CodingType: 0x92, LangCode: "eng"},
+	}
+	got, err := ParseClip("00001", clipFile(1000, seconds(600), seconds(4200), streams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "00001" || got.RecordingRate != 6000000 || got.SourcePackets != 1000 {
+		t.Errorf("got clip %+v, want 00001 with 1000 source packets at 6000000", got)
+	}
+	if s := got.Size(); s != 192000 {
+		t.Errorf("got size %d, want 192000", s)
+	}
+	if d := got.Duration(); d != time.Hour {
+		t.Errorf("got duration %v, want 1h", d)
+	}
+	want := []Stream{
+		{PID: 0x1011, CodingType: 0x24, Type: Video, CodecID: "V_MPEGH/ISO/HEVC", CodecShort: "MpegH", VideoSize: "1920x1080", FrameRate: "23.976"},
+		{PID: 0x1100, CodingType: 0x83, Type: Audio, CodecID: "A_TRUEHD", CodecShort: "TrueHD", LangCode: "jpn", Channels: 6, SampleRate: 48000},
+		{PID: 0x1200, CodingType: 0x92, Type: Subtitles, CodecID: "S_HDMV/TEXTST", CodecShort: "TextST", LangCode: "eng"},
+	}
+	if !reflect.DeepEqual(got.Streams, want) {
+		t.Errorf("got streams %+v, want %+v", got.Streams, want)
+	}
+}
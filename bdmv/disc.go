@@ -0,0 +1,187 @@
+package bdmv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/achernya/autorip/discid"
+)
+
+// Disc is the parsed contents of a BDMV directory.
+type Disc struct {
+	// VolumeName is the volume label of the disc. For a mounted
+	// disc, this is the name of the mount point.
+	VolumeName string
+	// Name is the title of the disc from its metadata, if any.
+	Name string
+	// Playlists are sorted by name.
+	Playlists []*Playlist
+	// Clips are keyed by their 5-digit name.
+	Clips map[string]*Clip
+}
+
+// Open reads the BDMV directory of a mounted disc or a copy of
+// one. root may either be the BDMV directory itself, or its parent.
+func Open(root string) (*Disc, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Base(root) == "BDMV" {
+		root = filepath.Dir(root)
+	}
+	return Read(os.DirFS(root), filepath.Base(root))
+}
+
+// Read reads the BDMV directory at the root of fsys.
+func Read(fsys fs.FS, volumeName string) (*Disc, error) {
+	result := &Disc{
+		VolumeName: volumeName,
+		Clips:      make(map[string]*Clip),
+	}
+	playlists, err := fs.Glob(fsys, "BDMV/PLAYLIST/*.mpls")
+	if err != nil {
+		return nil, err
+	}
+	if len(playlists) == 0 {
+		return nil, fmt.Errorf("no playlists found in %s/BDMV/PLAYLIST", volumeName)
+	}
+	for _, filename := range playlists {
+		b, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, err
+		}
+		playlist, err := ParsePlaylist(path.Base(filename), b)
+		if err != nil {
+			return nil, err
+		}
+		result.Playlists = append(result.Playlists, playlist)
+	}
+	clips, err := fs.Glob(fsys, "BDMV/CLIPINF/*.clpi")
+	if err != nil {
+		return nil, err
+	}
+	for _, filename := range clips {
+		b, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(path.Base(filename), ".clpi")
+		clip, err := ParseClip(name, b)
+		if err != nil {
+			return nil, err
+		}
+		result.Clips[name] = clip
+	}
+	if result.Name, err = readName(fsys); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// discLibrary is the disc metadata in BDMV/META/DL.
+type discLibrary struct {
+	Name string `xml:"discinfo>title>name"`
+}
+
+// readName returns the title of the disc from its metadata,
+// preferring the English metadata if there are several languages.
+func readName(fsys fs.FS) (string, error) {
+	files, err := fs.Glob(fsys, "BDMV/META/DL/bdmt_*.xml")
+	if err != nil || len(files) == 0 {
+		return "", err
+	}
+	filename := files[0]
+	if slices.Contains(files, "BDMV/META/DL/bdmt_eng.xml") {
+		filename = "BDMV/META/DL/bdmt_eng.xml"
+	}
+	b, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return "", err
+	}
+	dl := discLibrary{}
+	if err := xml.Unmarshal(b, &dl); err != nil {
+		return "", fmt.Errorf("%s: %w", filename, err)
+	}
+	return strings.TrimSpace(dl.Name), nil
+}
+
+// Size returns the number of bytes of the clips the playlist plays.
+// Play items that only play part of a clip are only counted in
+// proportion.
+func (d *Disc) Size(p *Playlist) int64 {
+	var result int64
+	for _, item := range p.PlayItems {
+		clip, ok := d.Clips[item.Clip]
+		if !ok {
+			continue
+		}
+		clipDuration := clip.Duration()
+		itemDuration := item.duration()
+		if clipDuration == 0 || itemDuration >= clipDuration {
+			result += clip.Size()
+			continue
+		}
+		result += int64(float64(clip.Size()) * float64(itemDuration) / float64(clipDuration))
+	}
+	return result
+}
+
+// Titles returns the playlists that are at least minLength long,
+// which are the playlists makemkvcon reports as titles. Playlists
+// that play exactly the same clips as an earlier playlist are
+// skipped, as makemkvcon does.
+func (d *Disc) Titles(minLength time.Duration) []*Playlist {
+	result := make([]*Playlist, 0)
+	seen := make(map[string]bool)
+	for _, playlist := range d.Playlists {
+		if playlist.Duration() < minLength {
+			continue
+		}
+		key := fmt.Sprintf("%s/%v", Segments(playlist), playlist.Duration())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, playlist)
+	}
+	return result
+}
+
+// Segments returns the clips the playlist plays, in order, as a
+// comma-separated list of clip numbers, like makemkvcon's
+// SegmentsMap.
+func Segments(p *Playlist) string {
+	segments := make([]string, 0, len(p.PlayItems))
+	for _, item := range p.PlayItems {
+		segments = append(segments, strings.TrimLeft(item.Clip, "0"))
+		if segments[len(segments)-1] == "" {
+			segments[len(segments)-1] = "0"
+		}
+	}
+	return strings.Join(segments, ",")
+}
+
+// DiscID returns the disc as used for fingerprinting, with the titles
+// that are at least minLength long.
+func (d *Disc) DiscID(minLength time.Duration) *discid.Disc {
+	result := &discid.Disc{
+		Name:   d.VolumeName,
+		Titles: make([]*discid.Title, 0),
+	}
+	for _, playlist := range d.Titles(minLength) {
+		result.Titles = append(result.Titles, &discid.Title{
+			Filename: playlist.Name,
+			Size:     d.Size(playlist),
+			Duration: discid.FormatDuration(playlist.Duration()),
+		})
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package bdmv
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/achernya/autorip/discid"
+)
+
+// syntheticDisc returns a BDMV tree with a short menu loop, a main
+// feature split across two clips, a duplicate of the main feature,
+// and an extra that only plays half of its clip.
+func syntheticDisc() fstest.MapFS {
+	streams := []Stream{
+		{PID: 0x1011, CodingType: 0x1b, Type: Video},
+		{PID: 0x1100, CodingType: 0x86, Type: Audio, LangCode: "eng"},
+		{PID: 0x1200, CodingType: 0x90, Type: Subtitles, LangCode: "eng"},
+	}
+	feature := []PlayItem{
+		{Clip: "00001", OutTime: seconds(3600)},
+		{Clip: "00002", OutTime: seconds(1800)},
+	}
+	chapters := []Mark{
+		{Type: MarkEntry, PlayItem: 0},
+		{Type: MarkEntry, PlayItem: 1},
+	}
+	return fstest.MapFS{
+		"BDMV/PLAYLIST/00000.mpls": {Data: playlistFile([]PlayItem{{Clip: "00000", OutTime: seconds(30)}}, nil, streams[:1])},
+		"BDMV/PLAYLIST/00100.mpls": {Data: playlistFile([]PlayItem{{Clip: "00003", OutTime: seconds(150)}}, nil, streams)},
+		"BDMV/PLAYLIST/00800.mpls": {Data: playlistFile(feature, chapters, streams)},
+		"BDMV/PLAYLIST/00801.mpls": {Data: playlistFile(feature, chapters, streams)},
+		"BDMV/CLIPINF/00000.clpi":  {Data: clipFile(100, 0, seconds(30), streams[:1])},
+		"BDMV/CLIPINF/00001.clpi":  {Data: clipFile(3000, 0, seconds(3600), streams)},
+		"BDMV/CLIPINF/00002.clpi":  {Data: clipFile(1500, 0, seconds(1800), streams)},
+		"BDMV/CLIPINF/00003.clpi":  {Data: clipFile(200, 0, seconds(300), streams)},
+		"BDMV/META/DL/bdmt_eng.xml": {Data: []byte(`<?xml version="1.0" encoding="utf-8"?>
+<disclib xmlns="urn:BDA:bdmv;disclib" xmlns:di="urn:BDA:bdmv;discinfo">
+  <di:discinfo><di:title><di:name> Film </di:name></di:title></di:discinfo>
+</disclib>`)},
+		"BDMV/META/DL/bdmt_fra.xml": {Data: []byte(`<disclib><discinfo><title><name>Le Film</name></title></discinfo></disclib>`)},
+	}
+}
+
+func TestRead(t *testing.T) {
+	disc, err := Read(syntheticDisc(), "FILM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disc.VolumeName != "FILM" || disc.Name != "Film" {
+		t.Errorf("got %+q (%+q), want FILM (Film)", disc.VolumeName, disc.Name)
+	}
+	if len(disc.Playlists) != 4 || len(disc.Clips) != 4 {
+		t.Errorf("got %d playlists and %d clips, want 4 and 4", len(disc.Playlists), len(disc.Clips))
+	}
+	names := []string{}
+	for _, playlist := range disc.Titles(120 * time.Second) {
+		names = append(names, playlist.Name)
+	}
+	if want := []string{"00100.mpls", "00800.mpls"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got titles %v, want %v", names, want)
+	}
+	want := &discid.Disc{
+		Name: "FILM",
+		Titles: []*discid.Title{
+			{Filename: "00100.mpls", Size: 100 * sourcePacketSize, Duration: "0:02:30"},
+			{Filename: "00800.mpls", Size: 4500 * sourcePacketSize, Duration: "1:30:00"},
+		},
+	}
+	if got := disc.DiscID(120 * time.Second); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadNoPlaylists(t *testing.T) {
+	if _, err := Read(fstest.MapFS{}, "EMPTY"); err == nil {
+		t.Error("Read unexpectedly succeeded without any playlists")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "FILM")
+	if err := os.CopyFS(root, syntheticDisc()); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{root, filepath.Join(root, "BDMV")} {
+		disc, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if disc.VolumeName != "FILM" || len(disc.Playlists) != 4 {
+			t.Errorf("Open(%s) got %s with %d playlists, want FILM with 4", dir, disc.VolumeName, len(disc.Playlists))
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	p := &Playlist{PlayItems: []PlayItem{{Clip: "00000"}, {Clip: "00012"}, {Clip: "00003"}}}
+	if got := Segments(p); got != "0,12,3" {
+		t.Errorf("got %s, want 0,12,3", got)
+	}
+}
@@ -0,0 +1,246 @@
+// Package bdmv parses the playlists (.mpls) and clip information
+// (.clpi) files of a Blu-ray disc's BDMV directory, so that a disc
+// can be analyzed without makemkvcon.
+//
+// All structures are big-endian, and are described in the Blu-ray
+// Disc Read-Only Format specification, part 3.
+package bdmv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const (
+	// ticksPerSecond is the resolution of all timestamps in
+	// playlists and clips.
+	ticksPerSecond = 45000
+
+	// MarkEntry is the MarkType of chapter marks.
+	MarkEntry = 1
+)
+
+// Ticks is a duration measured in 45kHz ticks.
+type Ticks uint32
+
+// Duration converts the ticks into a time.Duration.
+func (t Ticks) Duration() time.Duration {
+	return time.Duration(t) * time.Second / ticksPerSecond
+}
+
+// PlayItem is a single contiguous section of a clip.
+type PlayItem struct {
+	// Clip is the 5-digit name of the clip, e.g., "00001".
+	Clip    string
+	InTime  Ticks
+	OutTime Ticks
+	// Angles are the clips of the alternate angles, if any. The
+	// first angle is Clip.
+	Angles []string
+}
+
+func (item PlayItem) duration() time.Duration {
+	if item.OutTime <= item.InTime {
+		return 0
+	}
+	return (item.OutTime - item.InTime).Duration()
+}
+
+// Mark is a point of interest in a playlist.
+type Mark struct {
+	Type     uint8
+	PlayItem int
+	Time     Ticks
+}
+
+// Playlist is a parsed .mpls file.
+type Playlist struct {
+	// Name is the filename of the playlist, e.g., "00800.mpls".
+	Name      string
+	PlayItems []PlayItem
+	Marks     []Mark
+	// Streams are the streams of the first play item, which are
+	// the streams makemkvcon shows for the playlist.
+	Streams []Stream
+}
+
+// Duration returns the total playback time of the playlist.
+func (p *Playlist) Duration() time.Duration {
+	var total time.Duration
+	for _, item := range p.PlayItems {
+		total += item.duration()
+	}
+	return total
+}
+
+// Chapters returns the number of chapter marks in the playlist.
+func (p *Playlist) Chapters() int {
+	result := 0
+	for _, mark := range p.Marks {
+		if mark.Type == MarkEntry {
+			result++
+		}
+	}
+	return result
+}
+
+// Angles returns the number of angles of the playlist.
+func (p *Playlist) Angles() int {
+	result := 1
+	for _, item := range p.PlayItems {
+		result = max(result, len(item.Angles)+1)
+	}
+	return result
+}
+
+// reader is a bounds-checked big-endian reader over a byte slice. The
+// first out of bounds access is remembered in err, and every
+// following access returns zero values, so that parsers can check
+// for errors once per structure.
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || r.off+n > len(r.b) {
+		r.err = fmt.Errorf("unexpected end of data at offset %d (want %d bytes of %d)", r.off, n, len(r.b))
+		return make([]byte, n)
+	}
+	result := r.b[r.off : r.off+n]
+	r.off += n
+	return result
+}
+
+func (r *reader) skip(n int)        { r.bytes(n) }
+func (r *reader) u8() uint8         { return r.bytes(1)[0] }
+func (r *reader) u16() uint16       { return binary.BigEndian.Uint16(r.bytes(2)) }
+func (r *reader) u32() uint32       { return binary.BigEndian.Uint32(r.bytes(4)) }
+func (r *reader) str(n int) string  { return string(r.bytes(n)) }
+func (r *reader) seek(off uint32)   { r.off = int(off) }
+func (r *reader) sub(n int) *reader { return &reader{b: r.bytes(n)} }
+
+// header checks the type indicator and version shared by every BDMV
+// file.
+func (r *reader) header(magic string) {
+	if got := r.str(4); r.err == nil && got != magic {
+		r.err = fmt.Errorf("got type indicator %+q, want %+q", got, magic)
+	}
+	r.skip(4) // version_number
+}
+
+// ParsePlaylist parses the contents of an .mpls file.
+func ParsePlaylist(name string, b []byte) (*Playlist, error) {
+	r := &reader{b: b}
+	r.header("MPLS")
+	playlistStart := r.u32()
+	marksStart := r.u32()
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", name, r.err)
+	}
+	result := &Playlist{Name: name}
+
+	r.seek(playlistStart)
+	r.u32()   // length
+	r.skip(2) // reserved
+	items := int(r.u16())
+	r.u16() // number_of_SubPaths
+	for index := range items {
+		item := r.sub(int(r.u16()))
+		result.PlayItems = append(result.PlayItems, item.playItem(index == 0, &result.Streams))
+		if item.err != nil {
+			return nil, fmt.Errorf("%s: play item %d: %w", name, index, item.err)
+		}
+	}
+
+	r.seek(marksStart)
+	r.u32() // length
+	marks := int(r.u16())
+	for range marks {
+		r.skip(1) // reserved
+		mark := Mark{Type: r.u8(), PlayItem: int(r.u16()), Time: Ticks(r.u32())}
+		r.skip(6) // entry_ES_PID, duration
+		result.Marks = append(result.Marks, mark)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", name, r.err)
+	}
+	return result, nil
+}
+
+// playItem parses a PlayItem. The streams of the STN table are only
+// parsed if withStreams is set.
+func (r *reader) playItem(withStreams bool, streams *[]Stream) PlayItem {
+	item := PlayItem{Clip: r.str(5)}
+	r.skip(4) // Clip_codec_identifier
+	multiAngle := r.u16()&0x10 != 0
+	r.skip(1) // ref_to_STC_id
+	item.InTime = Ticks(r.u32())
+	item.OutTime = Ticks(r.u32())
+	r.skip(8) // UO_mask_table
+	r.skip(1) // PlayItem_random_access_flag
+	r.skip(3) // still_mode, still_time
+	if multiAngle {
+		angles := int(r.u8())
+		r.skip(1) // is_different_audios, is_seamless_angle_change
+		for range angles - 1 {
+			item.Angles = append(item.Angles, r.str(5))
+			r.skip(5) // Clip_codec_identifier, ref_to_STC_id
+		}
+	}
+	if !withStreams || r.err != nil {
+		return item
+	}
+	stn := r.sub(int(r.u16()))
+	*streams = stn.streamTable()
+	if stn.err != nil {
+		r.err = fmt.Errorf("STN table: %w", stn.err)
+	}
+	return item
+}
+
+// streamTable parses the streams of an STN table, skipping menus and
+// picture-in-picture streams, which makemkvcon does not save either.
+func (r *reader) streamTable() []Stream {
+	r.skip(2) // reserved
+	video := int(r.u8())
+	audio := int(r.u8())
+	subtitles := int(r.u8())
+	menus := int(r.u8())
+	r.skip(8) // secondary streams, reserved
+	result := make([]Stream, 0, video+audio+subtitles)
+	for index := range video + audio + subtitles + menus {
+		entry := r.sub(int(r.u8()))
+		pid := entry.streamPID()
+		attributes := r.sub(int(r.u8()))
+		stream := attributes.streamAttributes()
+		stream.PID = pid
+		if r.err == nil {
+			r.err = attributes.err
+		}
+		if index < video+audio+subtitles {
+			result = append(result, stream)
+		}
+	}
+	return result
+}
+
+// streamPID returns the PID of a stream_entry.
+func (r *reader) streamPID() uint16 {
+	switch r.u8() {
+	case 1: // stream of the play item's clip
+		return r.u16()
+	case 2: // stream of a sub path
+		r.skip(2) // ref_to_SubPath_id, ref_to_subClip_entry_id
+		return r.u16()
+	case 3, 4: // stream of a sub path, in-mux
+		r.skip(1) // ref_to_SubPath_id
+		return r.u16()
+	}
+	return 0
+}
@@ -0,0 +1,205 @@
+package bdmv
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writer builds synthetic BDMV files for tests.
+type writer struct {
+	b []byte
+}
+
+func (w *writer) u8(v uint8)       { w.b = append(w.b, v) }
+func (w *writer) u16(v uint16)     { w.b = binary.BigEndian.AppendUint16(w.b, v) }
+func (w *writer) u32(v uint32)     { w.b = binary.BigEndian.AppendUint32(w.b, v) }
+func (w *writer) str(v string)     { w.b = append(w.b, v...) }
+func (w *writer) zero(n int)       { w.b = append(w.b, make([]byte, n)...) }
+func (w *writer) set32(off, v int) { binary.BigEndian.PutUint32(w.b[off:], uint32(v)) }
+
+// block appends a structure prefixed by its length, which is either 1,
+// 2 or 4 bytes.
+func (w *writer) block(size int, f func(w *writer)) {
+	inner := &writer{}
+	f(inner)
+	switch size {
+	case 1:
+		w.u8(uint8(len(inner.b)))
+	case 2:
+		w.u16(uint16(len(inner.b)))
+	case 4:
+		w.u32(uint32(len(inner.b)))
+	}
+	w.b = append(w.b, inner.b...)
+}
+
+// attributes appends the stream_attributes of a stream.
+func (w *writer) attributes(s Stream) {
+	w.block(1, func(w *writer) {
+		w.u8(s.CodingType)
+		switch codecs[s.CodingType].Type {
+		case Video:
+			w.u8(0x61) // 1080p, 23.976
+		case Audio:
+			w.u8(0x61) // multi-channel, 48kHz
+			w.str(s.LangCode)
+		default:
+			if s.CodingType == 0x92 {
+				w.u8(1) // character_code
+			}
+			w.str(s.LangCode)
+		}
+		w.zero(4) // reserved, as on real discs
+	})
+}
+
+// playlistFile returns the contents of an .mpls file. The streams are
+// added to the first play item.
+func playlistFile(items []PlayItem, marks []Mark, streams []Stream) []byte {
+	w := &writer{}
+	w.str("MPLS0200")
+	w.zero(32) // addresses and reserved, set below
+	w.set32(8, len(w.b))
+	w.block(4, func(w *writer) {
+		w.zero(2)
+		w.u16(uint16(len(items)))
+		w.u16(0)
+		for index, item := range items {
+			w.block(2, func(w *writer) {
+				w.str(item.Clip)
+				w.str("M2TS")
+				if len(item.Angles) > 0 {
+					w.u16(0x10)
+				} else {
+					w.u16(0)
+				}
+				w.u8(0)
+				w.u32(uint32(item.InTime))
+				w.u32(uint32(item.OutTime))
+				w.zero(12)
+				if len(item.Angles) > 0 {
+					w.u8(uint8(len(item.Angles) + 1))
+					w.u8(0)
+					for _, angle := range item.Angles {
+						w.str(angle)
+						w.str("M2TS")
+						w.u8(0)
+					}
+				}
+				w.block(2, func(w *writer) {
+					counts := make(map[string]uint8)
+					if index == 0 {
+						for _, s := range streams {
+							counts[s.Type]++
+						}
+					}
+					w.zero(2)
+					w.u8(counts[Video])
+					w.u8(counts[Audio])
+					w.u8(counts[Subtitles])
+					w.u8(counts[Menu])
+					w.zero(8)
+					// Streams are grouped by type, in the
+					// same order as the counts.
+					for _, t := range []string{Video, Audio, Subtitles, Menu} {
+						for _, s := range streams {
+							if index != 0 || s.Type != t {
+								continue
+							}
+							w.block(1, func(w *writer) {
+								w.u8(1)
+								w.u16(s.PID)
+								w.zero(6)
+							})
+							w.attributes(s)
+						}
+					}
+				})
+			})
+		}
+	})
+	w.set32(12, len(w.b))
+	w.block(4, func(w *writer) {
+		w.u16(uint16(len(marks)))
+		for _, mark := range marks {
+			w.u8(0)
+			w.u8(mark.Type)
+			w.u16(uint16(mark.PlayItem))
+			w.u32(uint32(mark.Time))
+			w.zero(6)
+		}
+	})
+	return w.b
+}
+
+func seconds(s int) Ticks {
+	return Ticks(s * ticksPerSecond)
+}
+
+func TestParsePlaylist(t *testing.T) {
+	items := []PlayItem{
+		{Clip: "00001", InTime: seconds(10), OutTime: seconds(3610)},
+		{Clip: "00002", InTime: 0, OutTime: seconds(1800), Angles: []string{"00003"}},
+	}
+	marks := []Mark{
+		{Type: MarkEntry, PlayItem: 0, Time: seconds(10)},
+		{Type: MarkEntry, PlayItem: 1, Time: 0},
+		{Type: 2, PlayItem: 1, Time: seconds(60)},
+	}
+	streams := []Stream{
+		{PID: 0x1011, CodingType: 0x1b},
+		{PID: 0x1100, CodingType: 0x86, LangCode: "eng"},
+		{PID: 0x1101, CodingType: 0x81, LangCode: "fra"},
+		{PID: 0x1200, CodingType: 0x90, LangCode: "eng"},
+		{PID: 0x1400, CodingType: 0x91, LangCode: "eng"},
+	}
+	for index := range streams {
+		streams[index].Type = codecs[streams[index].CodingType].Type
+	}
+	got, err := ParsePlaylist("00800.mpls", playlistFile(items, marks, streams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.PlayItems, items) {
+		t.Errorf("got play items %+v, want %+v", got.PlayItems, items)
+	}
+	if !reflect.DeepEqual(got.Marks, marks) {
+		t.Errorf("got marks %+v, want %+v", got.Marks, marks)
+	}
+	if d := got.Duration(); d != 90*time.Minute {
+		t.Errorf("got duration %v, want 1h30m", d)
+	}
+	if c := got.Chapters(); c != 2 {
+		t.Errorf("got %d chapters, want 2", c)
+	}
+	if a := got.Angles(); a != 2 {
+		t.Errorf("got %d angles, want 2", a)
+	}
+	want := []Stream{
+		{PID: 0x1011, CodingType: 0x1b, Type: Video, CodecID: "V_MPEG4/ISO/AVC", CodecShort: "Mpeg4", VideoSize: "1920x1080", FrameRate: "23.976"},
+		{PID: 0x1100, CodingType: 0x86, Type: Audio, CodecID: "A_DTS", CodecShort: "DTS-HD MA", LangCode: "eng", Channels: 6, SampleRate: 48000},
+		{PID: 0x1101, CodingType: 0x81, Type: Audio, CodecID: "A_AC3", CodecShort: "DD", LangCode: "fra", Channels: 6, SampleRate: 48000},
+		{PID: 0x1200, CodingType: 0x90, Type: Subtitles, CodecID: "S_HDMV/PGS", CodecShort: "PGS", LangCode: "eng"},
+	}
+	if !reflect.DeepEqual(got.Streams, want) {
+		t.Errorf("got streams %+v, want %+v", got.Streams, want)
+	}
+}
+
+func TestParsePlaylistErrors(t *testing.T) {
+	valid := playlistFile([]PlayItem{{Clip: "00001", OutTime: seconds(60)}}, nil, nil)
+	tests := map[string][]byte{
+		"empty":       {},
+		"wrong magic": append([]byte("HDMV"), valid[4:]...),
+		"truncated":   valid[:len(valid)-3],
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePlaylist("00000.mpls", b); err == nil {
+				t.Error("ParsePlaylist unexpectedly succeeded")
+			}
+		})
+	}
+}
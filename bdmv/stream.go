@@ -0,0 +1,107 @@
+package bdmv
+
+import "fmt"
+
+// Stream types, named the same as makemkvcon names them.
+const (
+	Video     = "Video"
+	Audio     = "Audio"
+	Subtitles = "Subtitles"
+	Menu      = "Menu"
+)
+
+// codec describes a stream_coding_type.
+type codec struct {
+	Type  string
+	ID    string
+	Short string
+}
+
+// codecs are the stream_coding_types, with the codec IDs and short
+// names makemkvcon uses for them.
+var codecs = map[uint8]codec{
+	0x01: {Video, "V_MPEG1", "Mpeg1"},
+	0x02: {Video, "V_MPEG2", "Mpeg2"},
+	0x1b: {Video, "V_MPEG4/ISO/AVC", "Mpeg4"},
+	0x20: {Video, "V_MPEG4/ISO/MVC", "MVC"},
+	0x24: {Video, "V_MPEGH/ISO/HEVC", "MpegH"},
+	0xea: {Video, "V_MS/VFW/WVC1", "VC-1"},
+	0x80: {Audio, "A_LPCM", "LPCM"},
+	0x81: {Audio, "A_AC3", "DD"},
+	0x82: {Audio, "A_DTS", "DTS"},
+	0x83: {Audio, "A_TRUEHD", "TrueHD"},
+	0x84: {Audio, "A_EAC3", "DD+"},
+	0x85: {Audio, "A_DTS", "DTS-HD HR"},
+	0x86: {Audio, "A_DTS", "DTS-HD MA"},
+	0xa1: {Audio, "A_EAC3", "DD+"},
+	0xa2: {Audio, "A_DTS", "DTS-HD"},
+	0x90: {Subtitles, "S_HDMV/PGS", "PGS"},
+	0x91: {Menu, "", "IG"},
+	0x92: {Subtitles, "S_HDMV/TEXTST", "TextST"},
+}
+
+var (
+	videoFormats = map[uint8]string{
+		1: "720x480", 2: "720x576", 3: "720x480", 4: "1920x1080",
+		5: "1280x720", 6: "1920x1080", 7: "720x576", 8: "3840x2160",
+	}
+	frameRates = map[uint8]string{
+		1: "23.976", 2: "24", 3: "25", 4: "29.97", 6: "50", 7: "59.94",
+	}
+	channels = map[uint8]int{
+		1: 1, 3: 2, 6: 6, 12: 2,
+	}
+	sampleRates = map[uint8]int{
+		1: 48000, 4: 96000, 5: 192000, 12: 192000, 14: 96000,
+	}
+)
+
+// Stream describes a single elementary stream of a playlist or clip.
+type Stream struct {
+	PID        uint16
+	CodingType uint8
+	// Type is one of Video, Audio, Subtitles or Menu, or empty if
+	// the coding type is unknown.
+	Type       string
+	CodecID    string
+	CodecShort string
+	// LangCode is the ISO 639-2 code of audio and subtitle
+	// streams.
+	LangCode string
+	// VideoSize and FrameRate are only set for video streams.
+	VideoSize string
+	FrameRate string
+	// Channels and SampleRate are only set for audio streams.
+	Channels   int
+	SampleRate int
+}
+
+func (s Stream) String() string {
+	return fmt.Sprintf("%s %s %s (PID 0x%04x)", s.Type, s.CodecShort, s.LangCode, s.PID)
+}
+
+// streamAttributes parses the stream_attributes of an STN table, or
+// the StreamCodingInfo of a clip's ProgramInfo, which share their
+// layout up to the language code.
+func (r *reader) streamAttributes() Stream {
+	result := Stream{CodingType: r.u8()}
+	c := codecs[result.CodingType]
+	result.Type, result.CodecID, result.CodecShort = c.Type, c.ID, c.Short
+	switch c.Type {
+	case Video:
+		format := r.u8()
+		result.VideoSize = videoFormats[format>>4]
+		result.FrameRate = frameRates[format&0xf]
+	case Audio:
+		format := r.u8()
+		result.Channels = channels[format>>4]
+		result.SampleRate = sampleRates[format&0xf]
+		result.LangCode = r.str(3)
+	case Subtitles, Menu:
+		if result.CodingType == 0x92 {
+			r.skip(1) // character_code
+		}
+		result.LangCode = r.str(3)
+	}
+	return result
+}
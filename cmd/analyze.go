@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"fmt"
 	"path"
+	"slices"
 	"sync"
 
 	"github.com/achernya/autorip/db"
@@ -17,16 +19,25 @@ import (
 var (
 	driveIndex int
 	logid2     int
+	explain    bool
+	bdmvPath   string
+	videoTs    string
 )
 
 func init() {
 	rootCmd.AddCommand(analyzeCmd)
 	analyzeCmd.Flags().IntVarP(&driveIndex, "index", "i", -1, "drive to analyze. If set to -1, scan for drives")
 	analyzeCmd.Flags().IntVarP(&logid2, "log-id", "s", -1, "if set, load a previous log-id instead of reading a real disc")
-	analyzeCmd.MarkFlagsMutuallyExclusive("index", "log-id")
+	analyzeCmd.Flags().StringVar(&bdmvPath, "bdmv", "", "if set, analyze the BDMV directory of a mounted Blu-ray disc (or a copy of one) without makemkvcon")
+	analyzeCmd.Flags().StringVar(&videoTs, "video-ts", "", "if set, analyze the VIDEO_TS directory of a mounted DVD (or a copy of one) without makemkvcon")
+	analyzeCmd.MarkFlagsMutuallyExclusive("index", "log-id", "bdmv", "video-ts")
+	analyzeCmd.Flags().BoolVar(&explain, "explain", false, "print every candidate identity and how its confidence was calculated, and which titles and streams will be ripped")
 }
 
 func scan(mkv *makemkv.MakeMkv) ([]*makemkv.Drive, error) {
+	if bdmvPath != "" || videoTs != "" {
+		return nil, nil
+	}
 	if driveIndex == -1 && logid2 == -1 {
 		return mkv.ScanDrive()
 	}
@@ -39,6 +50,12 @@ func scan(mkv *makemkv.MakeMkv) ([]*makemkv.Drive, error) {
 }
 
 func analyze(mkv *makemkv.MakeMkv, drives []*makemkv.Drive) (*makemkv.Analysis, error) {
+	if bdmvPath != "" {
+		return mkv.AnalyzeBDMV(bdmvPath)
+	}
+	if videoTs != "" {
+		return mkv.AnalyzeDVD(videoTs)
+	}
 	if logid2 == -1 {
 		t := tui.NewTui()
 		p := tea.NewProgram(t)
@@ -93,6 +110,10 @@ var analyzeCmd = &cobra.Command{
 			return err
 		}
 		mkv := makemkv.New(d, viper.GetString(makemkvcon), viper.GetString(destdir))
+		mkv.LogPolicy, err = newLogPolicy()
+		if err != nil {
+			return err
+		}
 		drives, err := scan(mkv)
 		if err != nil {
 			return err
@@ -106,11 +127,41 @@ var analyzeCmd = &cobra.Command{
 			return err
 		}
 		defer index.Close()
-		i := makemkv.NewIdentifier(index)
-		_, err = i.MakePlan(analysis.DiscInfo)
+		i, err := newIdentifier(index)
+		if err != nil {
+			return err
+		}
+		plan, err := i.MakePlanFor(analysis)
 		if err != nil {
 			return err
 		}
-		return nil
+		if explain {
+			explainPlan(plan)
+		}
+		return mkv.RecordPlan(plan)
 	},
 }
+
+// explainPlan prints every candidate identity considered by the plan.
+func explainPlan(plan *makemkv.Plan) {
+	if plan.Similar != nil {
+		fmt.Printf("This disc %s\n", plan.Similar)
+	}
+	if len(plan.Candidates) == 0 {
+		fmt.Println("No candidate identities found")
+	}
+	for rank, candidate := range plan.Candidates {
+		fmt.Printf("%d. %s", rank+1, candidate.Explanation())
+	}
+	if plan.LowConfidence {
+		fmt.Printf("Confidence is below %.2f, rips will not be renamed\n", viper.GetFloat64(minConfidence))
+	}
+	fmt.Printf("Extras: %s, %d titles\n", plan.ExtrasPolicy, len(plan.Extras))
+	fmt.Printf("Streams: %s\n", plan.StreamPolicy)
+	for _, title := range slices.Concat(plan.RipTitles, plan.Extras) {
+		fmt.Printf("  title %d (%s, %v):\n", title.TitleIndex, title.Type, title.Duration)
+		for _, decision := range plan.Streams[title.TitleIndex] {
+			fmt.Printf("    %s\n", decision)
+		}
+	}
+}
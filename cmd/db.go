@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/discid"
+	"github.com/achernya/autorip/makemkv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	fingerprintVersion int
+	replaceConflicts   bool
+	migrateStatus      bool
+)
+
+func init() {
+	refingerprintCmd.Flags().IntVar(&fingerprintVersion, "version", int(discid.CurrentVersion), "fingerprint version to compute")
+	importCmd.Flags().BoolVar(&replaceConflicts, "replace", false, "replace identities that conflict with imported ones")
+	migrateCmd.Flags().BoolVar(&migrateStatus, "status", false, "print the schema version and every migration, without migrating")
+
+	dbCmd.AddCommand(refingerprintCmd)
+	dbCmd.AddCommand(exportCmd)
+	dbCmd.AddCommand(importCmd)
+	dbCmd.AddCommand(submitCmd)
+	dbCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+// signingKey returns the key this site signs its exports with.
+func signingKey() (ed25519.PrivateKey, error) {
+	return makemkv.LoadOrCreateKey(path.Join(viper.GetString(dbdir), "autorip.key"))
+}
+
+// loadTrusted returns the keys whose exports are accepted: those in
+// trustedkeys, and this site's own.
+func loadTrusted() ([]ed25519.PublicKey, error) {
+	key, err := signingKey()
+	if err != nil {
+		return nil, err
+	}
+	result := []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+	for _, id := range viper.GetStringSlice(trustedKeys) {
+		key, err := makemkv.ParseKeyID(id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+var (
+	dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Collection of subcommands for the autorip database",
+	}
+	refingerprintCmd = &cobra.Command{
+		Use:   "refingerprint",
+		Short: "Recompute disc fingerprints from stored logs with a newer fingerprint version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := db.OpenDB(path.Join(viper.GetString(dbdir), "autorip.sqlite"))
+			if err != nil {
+				return err
+			}
+			results, err := makemkv.Refingerprint(d, discid.Version(fingerprintVersion))
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VOLUME\tPREVIOUS\tCURRENT\tSTATUS")
+			for _, result := range results {
+				status := "created"
+				if !result.Created {
+					status = "existing"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Current.VolumeName, hex.EncodeToString(result.Previous.Fingerprint), hex.EncodeToString(result.Current.Fingerprint), status)
+			}
+			return w.Flush()
+		},
+	}
+	exportCmd = &cobra.Command{
+		Use:   "export FILE",
+		Short: "Write a signed file of the identities of every disc, to be imported by other sites",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := db.OpenDB(path.Join(viper.GetString(dbdir), "autorip.sqlite"))
+			if err != nil {
+				return err
+			}
+			key, err := signingKey()
+			if err != nil {
+				return err
+			}
+			f, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			count, err := makemkv.Export(d, key, f)
+			if err != nil {
+				f.Close() //nolint:errcheck
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Printf("Exported %d discs to %s, signed by %s\n", count, args[0], makemkv.KeyID(key.Public().(ed25519.PublicKey)))
+			return nil
+		},
+	}
+	importCmd = &cobra.Command{
+		Use:   "import FILE...",
+		Short: "Merge the identities exported by other sites, reporting conflicts",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := db.OpenDB(path.Join(viper.GetString(dbdir), "autorip.sqlite"))
+			if err != nil {
+				return err
+			}
+			trusted, err := loadTrusted()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FILE\tVOLUME\tIMPORTED\tLOCAL\tSTATUS")
+			for _, filename := range args {
+				f, err := os.Open(filename)
+				if err != nil {
+					return err
+				}
+				results, err := makemkv.Import(d, f, trusted, replaceConflicts)
+				f.Close() //nolint:errcheck
+				if err != nil {
+					return fmt.Errorf("%s: %w", filename, err)
+				}
+				for _, result := range results {
+					local := "-"
+					if result.Local != nil {
+						local = result.Local.TConst
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", filename, result.Disc.VolumeName, result.Disc.TConst, local, result.Status)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	submitCmd = &cobra.Command{
+		Use:   "submit FILE...",
+		Short: "Send files written by export to the ID service",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if viper.GetString(idService) == "" {
+				return fmt.Errorf("%s is not set", idService)
+			}
+			client := makemkv.NewIDClient(viper.GetString(idService))
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FILE\tVOLUME\tSUBMITTED\tREMOTE\tSTATUS")
+			for _, filename := range args {
+				f, err := os.Open(filename)
+				if err != nil {
+					return err
+				}
+				results, err := client.Submit(cmd.Context(), f)
+				f.Close() //nolint:errcheck
+				if err != nil {
+					return fmt.Errorf("%s: %w", filename, err)
+				}
+				for _, result := range results {
+					remote := result.Local
+					if remote == "" {
+						remote = "-"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", filename, result.VolumeName, result.TConst, remote, result.Status)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the database to the latest schema version, after backing it up",
+		Long: `Migrate the database to the latest schema version, after backing it up.
+
+Other commands migrate the database as needed as well; this is mostly
+useful with --status, to see which migrations are pending.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dsn := path.Join(viper.GetString(dbdir), "autorip.sqlite")
+			d, err := db.Open(dsn)
+			if err != nil {
+				return err
+			}
+			if migrateStatus {
+				current, statuses, err := db.Status(d)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Schema version %d, latest is %d\n", current, db.LatestVersion)
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED")
+				for _, status := range statuses {
+					applied := "pending"
+					if status.AppliedAt != nil {
+						applied = status.AppliedAt.Format(time.DateTime)
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Description, applied)
+				}
+				return w.Flush()
+			}
+			applied, backup, err := db.Migrate(d, dsn)
+			if backup != "" {
+				fmt.Printf("Backed up the database to %s\n", backup)
+			}
+			for _, m := range applied {
+				fmt.Printf("Applied migration %d (%s)\n", m.Version, m.Description)
+			}
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				fmt.Printf("Schema is already at the latest version, %d\n", db.LatestVersion)
+			}
+			return nil
+		},
+	}
+)
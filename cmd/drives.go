@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/makemkv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	drivesCmd.AddCommand(ejectCmd)
+	rootCmd.AddCommand(drivesCmd)
+}
+
+func scanDrives() (*makemkv.MakeMkv, []*makemkv.Drive, error) {
+	d, err := db.OpenDB(path.Join(viper.GetString(dbdir), "autorip.sqlite"))
+	if err != nil {
+		return nil, nil, err
+	}
+	mkv := makemkv.New(d, viper.GetString(makemkvcon), viper.GetString(destdir))
+	drives, err := mkv.ScanDrive()
+	if err != nil {
+		return nil, nil, err
+	}
+	return mkv, drives, nil
+}
+
+var (
+	drivesCmd = &cobra.Command{
+		Use:   "drives",
+		Short: "List attached disc drives and their state",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, drives, err := scanDrives()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "INDEX\tSTATE\tDISC\tPATH\tDRIVE")
+			for _, drive := range drives {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", drive.Index, drive.State, drive.DiscName, drive.DrivePath, drive.DriveName)
+			}
+			return w.Flush()
+		},
+	}
+	ejectCmd = &cobra.Command{
+		Use:   "eject INDEX",
+		Short: "Eject the disc from the given drive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := strconv.Atoi(args[0])
+			if err != nil {
+				return err
+			}
+			mkv, drives, err := scanDrives()
+			if err != nil {
+				return err
+			}
+			for _, drive := range drives {
+				if drive.Index == index {
+					return mkv.Eject(drive)
+				}
+			}
+			return fmt.Errorf("no drive with index %d", index)
+		},
+	}
+)
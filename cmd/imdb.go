@@ -2,22 +2,39 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/achernya/autorip/imdb"
+	pb "github.com/achernya/autorip/proto"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	imdbSource = "imdbsource"
 )
 
 var (
 	maxResults int
+	force      bool
 )
 
 func init() {
+	indexCmd.Flags().String("from", "", "fetch datasets from this mirror URL or local directory instead of IMDb")
+	viper.BindPFlag(imdbSource, indexCmd.Flags().Lookup("from"))
+	indexCmd.Flags().BoolVarP(&force, "force", "f", false, "rebuild the index even if the datasets are unchanged")
 	searchCmd.Flags().IntVarP(&maxResults, "max-results", "m", 10, "maximum number of results to show")
 
 	imdbCmd.AddCommand(indexCmd)
+	imdbCmd.AddCommand(infoCmd)
+	imdbCmd.AddCommand(rollbackCmd)
 	imdbCmd.AddCommand(searchCmd)
+	imdbCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(imdbCmd)
 }
 
@@ -30,16 +47,72 @@ var (
 		Use:   "index",
 		Short: "Build an index of IMDb data",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := imdb.Fetch(context.Background(), viper.GetString(dbdir))
+			changed, err := imdb.Fetch(context.Background(), viper.GetString(imdbSource), viper.GetString(dbdir))
 			if err != nil {
 				return err
 			}
-			index, err := imdb.NewIndex(viper.GetString(dbdir))
+			if len(changed) == 0 && !force && imdb.HasIndex(viper.GetString(dbdir)) {
+				fmt.Println("IMDb datasets are unchanged, not rebuilding the index")
+				return nil
+			}
+			return imdb.Rebuild(viper.GetString(dbdir))
+		},
+	}
+	infoCmd = &cobra.Command{
+		Use:   "info",
+		Short: "Print the provenance of the IMDb index",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := imdb.OpenIndex(viper.GetString(dbdir))
 			if err != nil {
 				return err
 			}
 			defer index.Close()
-			return index.Build()
+			snapshot, err := index.Snapshot()
+			if err != nil {
+				return err
+			}
+			if snapshot == nil {
+				return fmt.Errorf("index has no provenance, rebuild it with `autorip imdb index --force`")
+			}
+			result, err := json.MarshalIndent(snapshot, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(result))
+			return nil
+		},
+	}
+	rollbackCmd = &cobra.Command{
+		Use:   "rollback",
+		Short: "Restore the previous generation of the IMDb index",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return imdb.Rollback(viper.GetString(dbdir))
+		},
+	}
+	showCmd = &cobra.Command{
+		Use:   "show TCONST",
+		Short: "Print a single IMDb title, including its episodes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := imdb.OpenIndex(viper.GetString(dbdir))
+			if err != nil {
+				return err
+			}
+			defer index.Close()
+			title, err := index.Lookup(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			result, err := protojson.MarshalOptions{Multiline: true}.Marshal(title)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(result))
+			if len(title.GetEpisodes()) > 0 {
+				fmt.Println()
+				return printEpisodes(title)
+			}
+			return nil
 		},
 	}
 	searchCmd = &cobra.Command{
@@ -60,3 +133,25 @@ var (
 		},
 	}
 )
+
+// printEpisodes prints a human-readable table of the episodes of a
+// series, grouped by season.
+func printEpisodes(title *pb.Title) error {
+	years := fmt.Sprintf("%d", title.GetStartYear())
+	if title.GetEndYear() != 0 {
+		years += fmt.Sprintf("-%d", title.GetEndYear())
+	}
+	fmt.Printf("%s (%s), %d episodes\n", title.GetPrimaryTitle(), years, len(title.GetEpisodes()))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEASON\tEPISODE\tTCONST\tRUNTIME\tTITLE")
+	for _, episode := range title.GetEpisodes() {
+		runtime := "-"
+		if episode.GetRuntimeMinutes() != 0 {
+			runtime = fmt.Sprintf("%dm", episode.GetRuntimeMinutes())
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n",
+			episode.GetSeasonNumber(), episode.GetEpisodeNumber(), episode.GetTConst(),
+			runtime, strings.TrimSpace(episode.GetPrimaryTitle()))
+	}
+	return w.Flush()
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"log"
 	"path"
 	"sync"
 
@@ -14,7 +15,19 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+const (
+	degradedDir   = "degradeddir"
+	maxReadErrors = "maxreaderrors"
+	eject         = "eject"
+)
+
 func init() {
+	ripCmd.Flags().String(degradedDir, "", "if set, rips with too many read errors go here instead of destdir")
+	ripCmd.Flags().Int(maxReadErrors, 0, "number of read errors tolerated before a rip is considered degraded")
+	viper.BindPFlag(degradedDir, ripCmd.Flags().Lookup(degradedDir))
+	viper.BindPFlag(maxReadErrors, ripCmd.Flags().Lookup(maxReadErrors))
+	ripCmd.Flags().Bool(eject, true, "eject the disc once it has been ripped successfully")
+	viper.BindPFlag(eject, ripCmd.Flags().Lookup(eject))
 	rootCmd.AddCommand(ripCmd)
 }
 
@@ -27,6 +40,14 @@ var ripCmd = &cobra.Command{
 			return err
 		}
 		mkv := makemkv.New(d, viper.GetString(makemkvcon), viper.GetString(destdir))
+		mkv.QualityPolicy = makemkv.QualityPolicy{
+			MaxReadErrors: viper.GetInt(maxReadErrors),
+			DegradedDir:   viper.GetString(degradedDir),
+		}
+		mkv.LogPolicy, err = newLogPolicy()
+		if err != nil {
+			return err
+		}
 		drives, err := scan(mkv)
 		if err != nil {
 			return err
@@ -40,11 +61,17 @@ var ripCmd = &cobra.Command{
 			return err
 		}
 		defer index.Close()
-		i := makemkv.NewIdentifier(index)
-		plan, err := i.MakePlan(analysis.DiscInfo)
+		i, err := newIdentifier(index)
 		if err != nil {
 			return err
 		}
+		plan, err := i.MakePlanFor(analysis)
+		if err != nil {
+			return err
+		}
+		if err := mkv.RecordPlan(plan); err != nil {
+			return err
+		}
 
 		t := tui.NewTui()
 		p := tea.NewProgram(t)
@@ -59,21 +86,70 @@ var ripCmd = &cobra.Command{
 			p.Send(msg)
 		}
 
+		drive := drives[analysis.DriveIndex]
 		wg := sync.WaitGroup{}
 		wg.Add(1)
+		var ripErr error
 		go func() {
 			defer wg.Done()
 			defer p.Send(tui.Eof{})
-			err := mkv.Rip(drives[analysis.DriveIndex], plan, cb)
-			if err != nil {
-				panic(err)
-			}
+			ripErr = mkv.Rip(drive, plan, cb)
 		}()
 
 		if _, err := p.Run(); err != nil {
 			return err
 		}
 		wg.Wait()
+		if ripErr != nil {
+			// Leave the disc in the drive, so that it can
+			// be retried.
+			return ripErr
+		}
+		if viper.GetBool(eject) {
+			if err := mkv.Eject(drive); err != nil {
+				// The rip itself succeeded, so this is
+				// not worth failing over.
+				log.Printf("Unable to eject drive %d: %s\n", drive.Index, err)
+			}
+		}
 		return nil
 	},
 }
+
+// newIdentifier returns an Identifier configured from the config
+// file and flags.
+func newIdentifier(index imdb.GenericIndex) (*makemkv.Identifier, error) {
+	layout, err := makemkv.ParseExtrasLayout(viper.GetString(extras))
+	if err != nil {
+		return nil, err
+	}
+	i := makemkv.NewIdentifier(index)
+	i.MinConfidence = viper.GetFloat64(minConfidence)
+	i.ExtrasPolicy = makemkv.ExtrasPolicy{
+		Layout:    layout,
+		MinLength: viper.GetDuration(extrasMinLen),
+	}
+	i.StreamPolicy = makemkv.StreamPolicy{
+		Languages:      viper.GetStringSlice(languages),
+		LosslessOnly:   viper.GetBool(losslessOnly),
+		DropCommentary: viper.GetBool(dropCommentary),
+		KeepForced:     viper.GetBool(keepForced),
+	}
+	if url := viper.GetString(idService); url != "" {
+		i.IDService = makemkv.NewIDClient(url)
+	}
+	return i, nil
+}
+
+// newLogPolicy returns a LogPolicy configured from the config file and
+// flags.
+func newLogPolicy() (makemkv.LogPolicy, error) {
+	progress, err := makemkv.ParseProgressLogging(viper.GetString(logProgress))
+	if err != nil {
+		return makemkv.LogPolicy{}, err
+	}
+	return makemkv.LogPolicy{
+		Progress: progress,
+		Compress: viper.GetBool(compressLogs),
+	}, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/achernya/autorip/makemkv"
 	"github.com/charmbracelet/fang"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -16,6 +18,22 @@ const (
 	dbdir      = "dbdir"
 )
 
+// These are read by more than one command (e.g., both rip and
+// analyze), so they are flags of every command.
+const (
+	minConfidence  = "minconfidence"
+	extras         = "extras"
+	extrasMinLen   = "extrasminlength"
+	languages      = "languages"
+	losslessOnly   = "losslessonly"
+	dropCommentary = "dropcommentary"
+	keepForced     = "keepforced"
+	idService      = "idservice"
+	trustedKeys    = "trustedkeys"
+	logProgress    = "logprogress"
+	compressLogs   = "compresslogs"
+)
+
 var (
 	cfgFile = ""
 	rootCmd = &cobra.Command{
@@ -50,6 +68,28 @@ func init() {
 	viper.BindPFlag(makemkvcon, rootCmd.PersistentFlags().Lookup(makemkvcon))
 	viper.BindPFlag(destdir, rootCmd.PersistentFlags().Lookup(destdir))
 	viper.BindPFlag(dbdir, rootCmd.PersistentFlags().Lookup(dbdir))
+
+	rootCmd.PersistentFlags().Float64(minConfidence, 0.5, "identities with a lower confidence (between 0 and 1) are not used to rename rips")
+	viper.BindPFlag(minConfidence, rootCmd.PersistentFlags().Lookup(minConfidence))
+	rootCmd.PersistentFlags().String(extras, makemkv.ExtrasDiscard.String(), "how to keep titles other than the main content: discard, folder (Extras/) or plex (-featurette)")
+	rootCmd.PersistentFlags().Duration(extrasMinLen, 2*time.Minute, "shortest title that is kept as an extra")
+	viper.BindPFlag(extras, rootCmd.PersistentFlags().Lookup(extras))
+	viper.BindPFlag(extrasMinLen, rootCmd.PersistentFlags().Lookup(extrasMinLen))
+	rootCmd.PersistentFlags().StringSlice(languages, nil, "ISO 639-2 codes of the audio and subtitle languages to keep (default: all)")
+	rootCmd.PersistentFlags().Bool(losslessOnly, false, "drop lossy audio that duplicates a lossless stream")
+	rootCmd.PersistentFlags().Bool(dropCommentary, false, "drop commentary audio")
+	rootCmd.PersistentFlags().Bool(keepForced, false, "keep forced subtitles, even if they are not in one of the languages")
+	for _, flag := range []string{languages, losslessOnly, dropCommentary, keepForced} {
+		viper.BindPFlag(flag, rootCmd.PersistentFlags().Lookup(flag))
+	}
+	rootCmd.PersistentFlags().String(idService, "", "URL of an ID service (see serve-ids) to ask for the identity of discs not seen here before")
+	rootCmd.PersistentFlags().StringSlice(trustedKeys, nil, "public keys of the sites whose exports are imported")
+	viper.BindPFlag(idService, rootCmd.PersistentFlags().Lookup(idService))
+	viper.BindPFlag(trustedKeys, rootCmd.PersistentFlags().Lookup(trustedKeys))
+	rootCmd.PersistentFlags().String(logProgress, makemkv.ProgressKeep.String(), "how to store makemkvcon progress updates: keep, drop or downsample (to every 1%)")
+	rootCmd.PersistentFlags().Bool(compressLogs, false, "store each makemkvcon log compressed, rather than a row per line")
+	viper.BindPFlag(logProgress, rootCmd.PersistentFlags().Lookup(logProgress))
+	viper.BindPFlag(compressLogs, rootCmd.PersistentFlags().Lookup(compressLogs))
 }
 
 func initConfig() {
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/makemkv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	listen string
+)
+
+func init() {
+	serveIDsCmd.Flags().StringVar(&listen, "listen", "localhost:8765", "address to serve the ID service on")
+	rootCmd.AddCommand(serveIDsCmd)
+}
+
+var serveIDsCmd = &cobra.Command{
+	Use:   "serve-ids",
+	Short: "Serve the identities of the discs in the database over HTTP",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d, err := db.OpenDB(path.Join(viper.GetString(dbdir), "autorip.sqlite"))
+		if err != nil {
+			return err
+		}
+		trusted, err := loadTrusted()
+		if err != nil {
+			return err
+		}
+		log.Printf("Serving disc identities on %s\n", listen)
+		return http.ListenAndServe(listen, makemkv.NewIDServer(d, trusted))
+	},
+}
@@ -2,13 +2,14 @@ package db
 
 import (
 	"gorm.io/datatypes"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 type Session struct {
 	gorm.Model
 	RawLog            []MakeMkvLog
+	Outputs           []RipOutput
+	Identifications   []Identification
 	DiscFingerprintID *uint
 }
 
@@ -17,6 +18,9 @@ type MakeMkvLog struct {
 	SessionID uint
 	Args      datatypes.JSONSlice[string]
 	Entry     []MakeMkvLogEntry
+	// Compressed is the gzipped log, one entry per line, if it was
+	// stored compressed rather than as entries.
+	Compressed []byte
 }
 
 type MakeMkvLogEntry struct {
@@ -25,28 +29,99 @@ type MakeMkvLogEntry struct {
 	Entry        string
 }
 
+// RipOutput is a single file produced by ripping a title, along with
+// a summary of how cleanly the title was read from the disc.
+type RipOutput struct {
+	gorm.Model
+	SessionID    uint
+	TitleIndex   int
+	Path         string
+	ReadErrors   int
+	BadSectors   int
+	Retries      int
+	HashFailures int
+	// Degraded is set if the read errors exceeded the configured
+	// policy.
+	Degraded bool
+}
+
+// ImdbSnapshot records the provenance of an IMDb index that was used
+// to identify a disc.
+type ImdbSnapshot struct {
+	gorm.Model
+	// Snapshot is the ID of the snapshot, as reported by the index.
+	Snapshot string `gorm:"uniqueIndex"`
+	// Metadata is the full provenance of the snapshot.
+	Metadata datatypes.JSON
+}
+
+// Identification is what the disc in a session was identified as.
+type Identification struct {
+	gorm.Model
+	SessionID      uint
+	TConst         string
+	TitleType      string
+	PrimaryTitle   string
+	StartYear      int32
+	ImdbSnapshotID *uint
+	ImdbSnapshot   *ImdbSnapshot
+	// Confidence is that of the chosen candidate, and
+	// LowConfidence is set if it was too low to rename the rips.
+	Confidence    float64
+	LowConfidence bool
+	Candidates    []IdentificationCandidate
+	// Edition is which titles of the disc were ripped as the
+	// identity, e.g., the theatrical rather than the extended cut,
+	// as JSON.
+	Edition datatypes.JSON
+	// ImportedFrom is the public key of the site the
+	// identification was imported from, or empty if it was made
+	// here.
+	ImportedFrom []byte
+}
+
+// IdentificationCandidate is one of the possible identities that was
+// considered, and why.
+type IdentificationCandidate struct {
+	gorm.Model
+	IdentificationID uint
+	// Rank is the position in the list of candidates, where 0 was
+	// chosen.
+	Rank         int
+	TConst       string
+	PrimaryTitle string
+	Match        string
+	Confidence   float64
+	// Factors is the breakdown of the confidence.
+	Factors datatypes.JSON
+}
+
 type DiscFingerprint struct {
 	gorm.Model
 	Fingerprint []byte `gorm:"uniqueIndex"`
 	Name        string
 	VolumeName  string
+	// Version is the discid.Version the fingerprint was computed
+	// with. Fingerprints recorded before versioning are version 1.
+	Version int `gorm:"default:1"`
+	// Previous is the fingerprint of the same disc computed with an
+	// earlier version, if the disc was seen before the version
+	// changed.
+	PreviousID *uint
+	Previous   *DiscFingerprint
+	// Signature is the discid.Signature of the disc, for finding
+	// similar discs.
+	Signature datatypes.JSON
 }
 
+// OpenDB opens the database, and migrates it to the latest schema
+// version.
 func OpenDB(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(dsn))
+	db, err := Open(dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err := db.AutoMigrate(&Session{}); err != nil {
-		return nil, err
-	}
-	if err := db.AutoMigrate(&MakeMkvLog{}); err != nil {
-		return nil, err
-	}
-	if err := db.AutoMigrate(&MakeMkvLogEntry{}); err != nil {
-		return nil, err
-	}
-	if err := db.AutoMigrate(&DiscFingerprint{}); err != nil {
+	if _, _, err := Migrate(db, dsn); err != nil {
 		return nil, err
 	}
 	return db, nil
@@ -0,0 +1,277 @@
+package db
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/0001_baseline.sql
+var baselineSql string
+
+//go:embed migrations/0002_foreign_key_indexes.sql
+var foreignKeyIndexesSql string
+
+//go:embed migrations/0003_compressed_logs.sql
+var compressedLogsSql string
+
+//go:embed migrations/0004_identification_edition.sql
+var identificationEditionSql string
+
+// Migration is a single, numbered change to the schema. Once released,
+// a migration must never change: databases that already applied it
+// will not apply it again.
+type Migration struct {
+	Version     int
+	Description string
+	// Exactly one of SQL and Up is set.
+	SQL string
+	Up  func(tx *gorm.DB) error
+}
+
+// migrations are every migration, in order. The version of each is
+// its position in the list, starting at 1.
+var migrations = []Migration{
+	{Version: 1, Description: "baseline schema", Up: migrateBaseline},
+	{Version: 2, Description: "index foreign keys", SQL: foreignKeyIndexesSql},
+	{Version: 3, Description: "compressed logs", SQL: compressedLogsSql},
+	{Version: 4, Description: "identification editions", SQL: identificationEditionSql},
+}
+
+// LatestVersion is the schema version this autorip migrates to.
+var LatestVersion = migrations[len(migrations)-1].Version
+
+// ErrNewerSchema is returned when the database was migrated by a newer
+// autorip than this one.
+var ErrNewerSchema = errors.New("database schema is newer than this autorip supports")
+
+// SchemaVersion records a migration that was applied.
+type SchemaVersion struct {
+	Version     int `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// MigrationStatus is a migration, and when it was applied, if it was.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Open opens the database without migrating it. Most callers want
+// OpenDB instead.
+func Open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(sqlite.Open(dsn))
+}
+
+// Status returns the current schema version of the database, and
+// every migration known to this autorip, along with when it was
+// applied. A database that predates numbered migrations is at version
+// 0.
+func Status(d *gorm.DB) (int, []MigrationStatus, error) {
+	applied, err := appliedVersions(d)
+	if err != nil {
+		return 0, nil, err
+	}
+	current := 0
+	for version := range applied {
+		current = max(current, version)
+	}
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Migration: m}
+		if v, ok := applied[m.Version]; ok {
+			status.AppliedAt = &v.AppliedAt
+		}
+		result = append(result, status)
+	}
+	return current, result, nil
+}
+
+func appliedVersions(d *gorm.DB) (map[int]SchemaVersion, error) {
+	result := make(map[int]SchemaVersion)
+	if !d.Migrator().HasTable(&SchemaVersion{}) {
+		return result, nil
+	}
+	versions := []SchemaVersion{}
+	if err := d.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		result[v.Version] = v
+	}
+	return result, nil
+}
+
+// Migrate applies every pending migration to the database at dsn,
+// each in a transaction of its own. If the database already has
+// tables, it is first copied to a backup next to it. Migrate returns
+// the migrations that were applied, and the path of the backup, if
+// one was made.
+func Migrate(d *gorm.DB, dsn string) ([]Migration, string, error) {
+	current, statuses, err := Status(d)
+	if err != nil {
+		return nil, "", err
+	}
+	if current > LatestVersion {
+		return nil, "", fmt.Errorf("%w: version %d, want at most %d", ErrNewerSchema, current, LatestVersion)
+	}
+	pending := make([]Migration, 0)
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, "", nil
+	}
+
+	backup := ""
+	tables, err := d.Migrator().GetTables()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(tables) > 0 {
+		backup, err = backupDB(d, dsn, current)
+		if err != nil {
+			return nil, "", fmt.Errorf("backing up before migrating: %w", err)
+		}
+	}
+
+	err = d.Exec("CREATE TABLE IF NOT EXISTS `schema_version` (`version` integer PRIMARY KEY,`description` text,`applied_at` datetime)").Error
+	if err != nil {
+		return nil, backup, err
+	}
+	applied := make([]Migration, 0, len(pending))
+	for _, m := range pending {
+		log.Printf("Migrating database to version %d (%s)\n", m.Version, m.Description)
+		err := d.Transaction(func(tx *gorm.DB) error {
+			if m.Up != nil {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+			} else if err := tx.Exec(m.SQL).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaVersion{
+				Version:     m.Version,
+				Description: m.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return applied, backup, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, backup, nil
+}
+
+// backupDB copies the database at dsn to a file next to it, named
+// after the schema version it is at. In-memory databases are not
+// backed up.
+func backupDB(d *gorm.DB, dsn string, version int) (string, error) {
+	filename := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(filename, '?'); i >= 0 {
+		filename = filename[:i]
+	}
+	if filename == ":memory:" || strings.Contains(dsn, "mode=memory") {
+		return "", nil
+	}
+	backup := fmt.Sprintf("%s.v%d-%s.bak", filename, version, time.Now().Format("20060102T150405"))
+	if err := d.Exec("VACUUM INTO ?", backup).Error; err != nil {
+		return "", err
+	}
+	log.Printf("Backed up database to %s\n", backup)
+	return backup, nil
+}
+
+// migrateBaseline creates the schema that AutoMigrate maintained
+// before numbered migrations. Databases created by an older autorip
+// may lack some of its columns, which are added as well.
+func migrateBaseline(tx *gorm.DB) error {
+	if err := tx.Exec(baselineSql).Error; err != nil {
+		return err
+	}
+	baseline, err := gorm.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if sqlDB, err := baseline.DB(); err == nil {
+			sqlDB.Close() //nolint:errcheck
+		}
+	}()
+	if err := baseline.Exec(baselineSql).Error; err != nil {
+		return err
+	}
+	tables, err := baseline.Migrator().GetTables()
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if table == "sqlite_sequence" {
+			continue
+		}
+		want, err := tableColumns(baseline, table)
+		if err != nil {
+			return err
+		}
+		existing, err := tableColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		have := make(map[string]bool)
+		for _, column := range existing {
+			have[column.name] = true
+		}
+		for _, column := range want {
+			if have[column.name] {
+				continue
+			}
+			definition := fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", table, column.name, column.typ)
+			if column.dflt != nil {
+				definition += " DEFAULT " + *column.dflt
+			}
+			if err := tx.Exec(definition).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type tableColumn struct {
+	name string
+	typ  string
+	dflt *string
+}
+
+// tableColumns returns the columns of the table, in the order they
+// were defined.
+func tableColumns(d *gorm.DB, table string) ([]tableColumn, error) {
+	rows, err := d.Raw("SELECT name, type, dflt_value FROM pragma_table_info(?) ORDER BY cid", table).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+	result := make([]tableColumn, 0)
+	for rows.Next() {
+		column := tableColumn{}
+		if err := rows.Scan(&column.name, &column.typ, &column.dflt); err != nil {
+			return nil, err
+		}
+		result = append(result, column)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMigrationsAreNumbered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %q is version %d, want %d", m.Description, m.Version, i+1)
+		}
+		if (m.SQL == "") == (m.Up == nil) {
+			t.Errorf("migration %d must have exactly one of SQL and Up", m.Version)
+		}
+	}
+}
+
+func TestMigrateNew(t *testing.T) {
+	dsn := path.Join(t.TempDir(), "autorip.sqlite")
+	d, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, backup, err := Migrate(d, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != LatestVersion {
+		t.Errorf("applied %d migrations, want %d", len(applied), LatestVersion)
+	}
+	if backup != "" {
+		t.Errorf("backed up an empty database to %s", backup)
+	}
+	current, statuses, err := Status(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != LatestVersion {
+		t.Errorf("got version %d, want %d", current, LatestVersion)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %d was not applied", status.Version)
+		}
+	}
+	// Migrating again does nothing.
+	applied, backup, err = Migrate(d, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 || backup != "" {
+		t.Errorf("got %d migrations and backup %q when already up to date", len(applied), backup)
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	dsn := path.Join(t.TempDir(), "autorip.sqlite")
+	d, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A database created by AutoMigrate before fingerprints were
+	// versioned.
+	if err := d.Exec("CREATE TABLE `disc_fingerprints` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`fingerprint` blob,`name` text,`volume_name` text)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Exec("INSERT INTO `disc_fingerprints` (`fingerprint`, `volume_name`) VALUES (x'00', 'LEGACY')").Error; err != nil {
+		t.Fatal(err)
+	}
+	applied, backup, err := Migrate(d, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != LatestVersion {
+		t.Errorf("applied %d migrations, want %d", len(applied), LatestVersion)
+	}
+	if backup == "" {
+		t.Fatal("did not back up the database before migrating")
+	}
+	fp := DiscFingerprint{}
+	if err := d.First(&fp).Error; err != nil {
+		t.Fatal(err)
+	}
+	if fp.VolumeName != "LEGACY" || fp.Version != 1 {
+		t.Errorf("got %s version %d, want LEGACY version 1", fp.VolumeName, fp.Version)
+	}
+
+	if _, err := os.Stat(backup); err != nil {
+		t.Fatal(err)
+	}
+	old, err := Open(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Migrator().HasColumn(&DiscFingerprint{}, "Version") {
+		t.Error("backup was taken after migrating")
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	d, err := OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create(&SchemaVersion{Version: LatestVersion + 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Migrate(d, ":memory:"); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("got error %v, want %v", err, ErrNewerSchema)
+	}
+}
@@ -0,0 +1,28 @@
+-- The schema as maintained by AutoMigrate, before numbered migrations.
+-- Databases created before then already have some or all of these
+-- tables, so every statement must be safe to run against them.
+CREATE TABLE IF NOT EXISTS `sessions` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`disc_fingerprint_id` integer);
+CREATE INDEX IF NOT EXISTS `idx_sessions_deleted_at` ON `sessions`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `make_mkv_logs` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`session_id` integer,`args` JSON,CONSTRAINT `fk_sessions_raw_log` FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_make_mkv_logs_deleted_at` ON `make_mkv_logs`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `make_mkv_log_entries` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`make_mkv_log_id` integer,`entry` text,CONSTRAINT `fk_make_mkv_logs_entry` FOREIGN KEY (`make_mkv_log_id`) REFERENCES `make_mkv_logs`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_make_mkv_log_entries_deleted_at` ON `make_mkv_log_entries`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `disc_fingerprints` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`fingerprint` blob,`name` text,`volume_name` text,`version` integer DEFAULT 1,`previous_id` integer,`signature` JSON,CONSTRAINT `fk_disc_fingerprints_previous` FOREIGN KEY (`previous_id`) REFERENCES `disc_fingerprints`(`id`));
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_disc_fingerprints_fingerprint` ON `disc_fingerprints`(`fingerprint`);
+CREATE INDEX IF NOT EXISTS `idx_disc_fingerprints_deleted_at` ON `disc_fingerprints`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `rip_outputs` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`session_id` integer,`title_index` integer,`path` text,`read_errors` integer,`bad_sectors` integer,`retries` integer,`hash_failures` integer,`degraded` numeric,CONSTRAINT `fk_sessions_outputs` FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_rip_outputs_deleted_at` ON `rip_outputs`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `imdb_snapshots` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`snapshot` text,`metadata` JSON);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_imdb_snapshots_snapshot` ON `imdb_snapshots`(`snapshot`);
+CREATE INDEX IF NOT EXISTS `idx_imdb_snapshots_deleted_at` ON `imdb_snapshots`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `identifications` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`session_id` integer,`t_const` text,`title_type` text,`primary_title` text,`start_year` integer,`imdb_snapshot_id` integer,`confidence` real,`low_confidence` numeric,`imported_from` blob,CONSTRAINT `fk_sessions_identifications` FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`),CONSTRAINT `fk_identifications_imdb_snapshot` FOREIGN KEY (`imdb_snapshot_id`) REFERENCES `imdb_snapshots`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_identifications_deleted_at` ON `identifications`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `identification_candidates` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`identification_id` integer,`rank` integer,`t_const` text,`primary_title` text,`match` text,`confidence` real,`factors` JSON,CONSTRAINT `fk_identifications_candidates` FOREIGN KEY (`identification_id`) REFERENCES `identifications`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_identification_candidates_deleted_at` ON `identification_candidates`(`deleted_at`);
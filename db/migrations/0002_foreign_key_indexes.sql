@@ -0,0 +1,7 @@
+-- Index the foreign keys that are joined on to read logs and to find
+-- the identifications of a disc.
+CREATE INDEX `idx_sessions_disc_fingerprint_id` ON `sessions`(`disc_fingerprint_id`);
+CREATE INDEX `idx_make_mkv_logs_session_id` ON `make_mkv_logs`(`session_id`);
+CREATE INDEX `idx_make_mkv_log_entries_make_mkv_log_id` ON `make_mkv_log_entries`(`make_mkv_log_id`);
+CREATE INDEX `idx_identifications_session_id` ON `identifications`(`session_id`);
+CREATE INDEX `idx_identification_candidates_identification_id` ON `identification_candidates`(`identification_id`);
@@ -0,0 +1,3 @@
+-- Logs may be stored as a single compressed blob instead of as
+-- entries.
+ALTER TABLE `make_mkv_logs` ADD COLUMN `compressed` blob;
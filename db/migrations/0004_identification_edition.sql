@@ -0,0 +1,3 @@
+-- Identifications record which titles were ripped, i.e., the edition
+-- of the identity that is on the disc.
+ALTER TABLE `identifications` ADD COLUMN `edition` JSON;
@@ -2,7 +2,9 @@ package db
 
 import (
 	"bytes"
+	"compress/gzip"
 	"database/sql"
+	"errors"
 	"io"
 
 	"gorm.io/gorm"
@@ -35,7 +37,16 @@ func (r *logReader) Read(p []byte) (n int, err error) {
 	return
 }
 
+// NewLogReader returns a reader of the log's entries, one per line,
+// whether the log was stored as entries or compressed.
 func NewLogReader(db *gorm.DB, logid uint) (io.Reader, error) {
+	compressed := []byte{}
+	if err := db.Raw("SELECT compressed FROM make_mkv_logs WHERE id = ?", logid).Row().Scan(&compressed); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if len(compressed) > 0 {
+		return gzip.NewReader(bytes.NewReader(compressed))
+	}
 	result := &logReader{
 		db: db,
 	}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// logBatchSize is how many entries are buffered before they
+	// are inserted.
+	logBatchSize = 1000
+	// logFlushInterval is how long entries are buffered for at
+	// most, so that the log of a long rip is stored as it goes.
+	logFlushInterval = time.Second
+)
+
+// LogWriter stores the entries of a log. Entries are buffered, and
+// inserted in batches, each in a single transaction. If the log is
+// compressed, the entries are instead gzipped in memory, and stored
+// as a single blob when the writer is closed; a log that is never
+// closed is lost.
+type LogWriter struct {
+	db        *gorm.DB
+	logID     uint
+	batch     []MakeMkvLogEntry
+	lastFlush time.Time
+	// compressed and gz are only set if the log is compressed.
+	compressed *bytes.Buffer
+	gz         *gzip.Writer
+}
+
+func NewLogWriter(db *gorm.DB, logID uint, compress bool) *LogWriter {
+	result := &LogWriter{
+		db:        db,
+		logID:     logID,
+		batch:     make([]MakeMkvLogEntry, 0, logBatchSize),
+		lastFlush: time.Now(),
+	}
+	if compress {
+		result.compressed = &bytes.Buffer{}
+		result.gz = gzip.NewWriter(result.compressed)
+	}
+	return result
+}
+
+// WriteEntry adds an entry to the log, which must not contain a
+// newline.
+func (w *LogWriter) WriteEntry(entry string) error {
+	if w.gz != nil {
+		_, err := w.gz.Write([]byte(entry + "\n"))
+		return err
+	}
+	w.batch = append(w.batch, MakeMkvLogEntry{MakeMkvLogID: w.logID, Entry: entry})
+	if len(w.batch) >= logBatchSize || time.Since(w.lastFlush) >= logFlushInterval {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush inserts the buffered entries. Compressed logs are only stored
+// by Close.
+//
+// Entries are inserted directly, rather than appended to the Entry
+// association of the MakeMkvLog, since gorm turns that into a giant
+// read-modify-write of every entry.
+func (w *LogWriter) Flush() error {
+	w.lastFlush = time.Now()
+	if len(w.batch) == 0 {
+		return nil
+	}
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(w.batch, logBatchSize).Error
+	})
+	w.batch = w.batch[:0]
+	return err
+}
+
+// Close stores whatever has not been stored yet.
+func (w *LogWriter) Close() error {
+	if w.gz == nil {
+		return w.Flush()
+	}
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	return w.db.Model(&MakeMkvLog{}).Where("id = ?", w.logID).Update("compressed", w.compressed.Bytes()).Error
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"testing"
+)
+
+func TestLogWriter(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			db, err := OpenDB(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			log := &MakeMkvLog{}
+			if err := db.Create(log).Error; err != nil {
+				t.Fatal(err)
+			}
+			// More than a batch, so that some entries are
+			// only stored by Close.
+			want := make([]string, 0)
+			w := NewLogWriter(db, log.ID, compress)
+			for i := range 2*logBatchSize + 1 {
+				want = append(want, fmt.Sprintf("line %d", i))
+				if err := w.WriteEntry(want[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries := int64(0)
+			if err := db.Model(&MakeMkvLogEntry{}).Count(&entries).Error; err != nil {
+				t.Fatal(err)
+			}
+			if compress != (entries == 0) {
+				t.Errorf("got %d entries with compress=%v", entries, compress)
+			}
+			r, err := NewLogReader(db, log.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0)
+			s := bufio.NewScanner(r)
+			for s.Scan() {
+				got = append(got, s.Text())
+			}
+			if err := s.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d lines, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("got %+q, want %+q", got[i], want[i])
+				}
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"slices"
+	"time"
 
 	"golang.org/x/crypto/cryptobyte"
 	"golang.org/x/crypto/cryptobyte/asn1"
@@ -39,26 +40,73 @@ type Title struct {
 	Filename string
 	// Size is the size of the file in bytes.
 	Size int64
-	// Duration is a hh:mm:ss string of the file's duration.
+	// Duration is a h:mm:ss string of the file's duration, as
+	// formatted by FormatDuration.
 	Duration string
 }
 
+// Version identifies the algorithm used to compute a fingerprint.
+// Fingerprints of different versions are never equal, even for the
+// same disc, so a new version must be introduced whenever the
+// encoding, or what goes into a Disc, changes.
+type Version int
+
+const (
+	// Version1 encodes the name, and the filename, size and
+	// duration of every title, without the version itself.
+	Version1 Version = 1
+	// Version2 encodes the version, and omits the size of each
+	// title. makemkvcon reports sizes as an estimate of its
+	// output, which is not stable across makemkvcon releases, and
+	// cannot be computed when a disc is analyzed natively.
+	//
+	//	Disc ::= SEQUENCE {
+	//	  version INTEGER,
+	//	  name OctetString,
+	//	  titles SEQUENCE OF SEQUENCE {
+	//	    filename OctetString,
+	//	    duration OctetString } }
+	Version2 Version = 2
+
+	// CurrentVersion is the version new fingerprints are
+	// computed with.
+	CurrentVersion = Version2
+)
+
+// Versions are all of the supported versions, from oldest to newest.
+var Versions = []Version{Version1, Version2}
+
+// Serialize returns the Version1 encoding of the Disc.
 func Serialize(d *Disc) ([]byte, error) {
+	return SerializeVersion(d, Version1)
+}
+
+// SerializeVersion returns the encoding of the Disc used by the given
+// version.
+func SerializeVersion(d *Disc, v Version) ([]byte, error) {
 	if d == nil {
 		return nil, fmt.Errorf("input must not be nil")
 	}
+	if !slices.Contains(Versions, v) {
+		return nil, fmt.Errorf("unsupported fingerprint version %d", v)
+	}
 	// Sort all of the filenames, otherwise a permutation could result in a different answer.
-	slices.SortFunc(d.Titles, func(a, b *Title) int {
+	titles := slices.SortedFunc(slices.Values(d.Titles), func(a, b *Title) int {
 		return cmp.Compare(a.Filename, b.Filename)
 	})
 	b := cryptobyte.NewBuilder(make([]byte, 0, 16))
 	b.AddASN1(asn1.SEQUENCE, func(outer *cryptobyte.Builder) {
+		if v != Version1 {
+			outer.AddASN1Int64(int64(v))
+		}
 		outer.AddASN1OctetString([]byte(d.Name))
 		outer.AddASN1(asn1.SEQUENCE, func(inner *cryptobyte.Builder) {
-			for _, t := range d.Titles {
+			for _, t := range titles {
 				inner.AddASN1(asn1.SEQUENCE, func(child *cryptobyte.Builder) {
 					child.AddASN1OctetString([]byte(t.Filename))
-					child.AddASN1Int64(t.Size)
+					if v == Version1 {
+						child.AddASN1Int64(t.Size)
+					}
 					child.AddASN1OctetString([]byte(t.Duration))
 				})
 			}
@@ -67,13 +115,25 @@ func Serialize(d *Disc) ([]byte, error) {
 	return b.Bytes()
 }
 
-// Fingerprint returns a SHA-256 hash of the given Disc.
+// Fingerprint returns a Version1 SHA-256 hash of the given Disc.
 func Fingerprint(d *Disc) ([]byte, error) {
-	b, err := Serialize(d)
+	return FingerprintVersion(d, Version1)
+}
+
+// FingerprintVersion returns a SHA-256 hash of the given Disc, using
+// the given version.
+func FingerprintVersion(d *Disc, v Version) ([]byte, error) {
+	b, err := SerializeVersion(d, v)
 	if err != nil {
 		return []byte{}, err
 	}
 	result := sha256.Sum256(b)
 	return result[:], nil
+}
 
+// FormatDuration formats a duration the way makemkvcon does, as
+// h:mm:ss, for discs that are analyzed without makemkvcon.
+func FormatDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"testing"
+	"time"
 )
 
 func TestSerializeNullDisc(t *testing.T) {
@@ -161,3 +162,68 @@ func TestAlternateOrdersSameFingerprint(t *testing.T) {
 		t.Errorf("serialization was not order-agnostic")
 	}
 }
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                     "0:00:00",
+		59*time.Second + 999*time.Millisecond: "0:00:59",
+		2*time.Hour + 3*time.Minute + 4*time.Second: "2:03:04",
+	}
+	for d, want := range tests {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%v) = %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestSerializeVersion2(t *testing.T) {
+	d := &Disc{
+		Name: "N",
+		Titles: []*Title{{
+			Filename: "f",
+			Size:     1234,
+			Duration: "1",
+		}},
+	}
+	// SEQUENCE { INTEGER { 2 } OCTET_STRING { "N" } SEQUENCE { SEQUENCE { OCTET_STRING { "f" } OCTET_STRING { "1" } } } }
+	expected := []byte{0x30, 0x10, 0x02, 0x01, 0x02, 0x04, 0x01, 'N', 0x30, 0x08, 0x30, 0x06, 0x04, 0x01, 'f', 0x04, 0x01, '1'}
+	result, err := SerializeVersion(d, Version2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result, expected) {
+		t.Errorf("got %x; want %x", result, expected)
+	}
+	if _, err := SerializeVersion(d, Version(0)); err == nil {
+		t.Error("SerializeVersion unexpectedly succeeded with an unsupported version")
+	}
+}
+
+func TestVersionsDiffer(t *testing.T) {
+	d := &Disc{
+		Name: "Disc 1",
+		Titles: []*Title{{
+			Filename: "00001.mpls",
+			Size:     5678,
+			Duration: "1:00:00",
+		}},
+	}
+	seen := make(map[string]Version)
+	for _, v := range Versions {
+		fp, err := FingerprintVersion(d, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := seen[string(fp)]; ok {
+			t.Errorf("versions %d and %d have the same fingerprint", other, v)
+		}
+		seen[string(fp)] = v
+	}
+	// Version2 does not depend on sizes.
+	before, _ := FingerprintVersion(d, Version2)
+	d.Titles[0].Size = 1
+	after, _ := FingerprintVersion(d, Version2)
+	if !bytes.Equal(before, after) {
+		t.Error("Version2 fingerprint changed with the size of a title")
+	}
+}
@@ -0,0 +1,89 @@
+package discid
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// durationTolerance is how many seconds the durations of two titles
+// may differ by, and still be considered the same title.
+const durationTolerance = 1
+
+// Feature is a single title of a Signature.
+type Feature struct {
+	Filename string
+	Seconds  int
+}
+
+// Signature summarizes the titles of a disc, so that discs that are
+// similar but have different fingerprints can be found, e.g., two
+// pressings of the same film, or the same disc read by two makemkvcon
+// releases. Sizes are not part of the signature, since they are the
+// part of a title most likely to change between releases.
+type Signature []Feature
+
+// NewSignature returns the signature of the disc.
+func NewSignature(d *Disc) (Signature, error) {
+	if d == nil {
+		return nil, fmt.Errorf("input must not be nil")
+	}
+	result := make(Signature, 0, len(d.Titles))
+	for _, t := range d.Titles {
+		seconds, err := parseDuration(t.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("title %s: %w", t.Filename, err)
+		}
+		result = append(result, Feature{Filename: t.Filename, Seconds: seconds})
+	}
+	slices.SortFunc(result, compareFeatures)
+	return result, nil
+}
+
+func compareFeatures(a, b Feature) int {
+	return cmp.Or(cmp.Compare(a.Filename, b.Filename), cmp.Compare(a.Seconds, b.Seconds))
+}
+
+// parseDuration parses a h:mm:ss duration into seconds.
+func parseDuration(duration string) (int, error) {
+	parts := strings.Split(duration, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("duration %+q is not h:mm:ss", duration)
+	}
+	result := 0
+	for _, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("duration %+q is not h:mm:ss: %w", duration, err)
+		}
+		result = result*60 + v
+	}
+	return result, nil
+}
+
+// Similarity returns the Jaccard index of the two signatures: the
+// number of titles they have in common, divided by the number of
+// distinct titles in either. Titles are in common if they have the
+// same filename, and their durations differ by at most a second.
+// Signatures must be sorted, as NewSignature returns them.
+func (s Signature) Similarity(other Signature) float64 {
+	if len(s) == 0 || len(other) == 0 {
+		return 0
+	}
+	common := 0
+	for i, j := 0, 0; i < len(s) && j < len(other); {
+		a, b := s[i], other[j]
+		if a.Filename == b.Filename && max(a.Seconds-b.Seconds, b.Seconds-a.Seconds) <= durationTolerance {
+			common++
+			i++
+			j++
+		} else if compareFeatures(a, b) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return float64(common) / float64(len(s)+len(other)-common)
+}
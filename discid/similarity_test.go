@@ -0,0 +1,71 @@
+package discid
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewSignature(t *testing.T) {
+	d := &Disc{
+		Titles: []*Title{
+			{Filename: "00801.mpls", Size: 1, Duration: "0:05:00"},
+			{Filename: "00800.mpls", Size: 2, Duration: "1:30:01"},
+		},
+	}
+	got, err := NewSignature(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Signature{{"00800.mpls", 5401}, {"00801.mpls", 300}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	d.Titles[0].Duration = "5 minutes"
+	if _, err := NewSignature(d); err == nil {
+		t.Error("NewSignature unexpectedly succeeded with an invalid duration")
+	}
+	if _, err := NewSignature(nil); err == nil {
+		t.Error("NewSignature unexpectedly succeeded with a nil disc")
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	base := Signature{{"00001.mpls", 100}, {"00002.mpls", 200}, {"00003.mpls", 300}, {"00800.mpls", 5400}}
+	tests := map[string]struct {
+		other Signature
+		want  float64
+	}{
+		"identical": {base, 1},
+		"off by a second": {
+			Signature{{"00001.mpls", 101}, {"00002.mpls", 199}, {"00003.mpls", 300}, {"00800.mpls", 5400}},
+			1,
+		},
+		"off by two seconds": {
+			Signature{{"00001.mpls", 102}, {"00002.mpls", 200}, {"00003.mpls", 300}, {"00800.mpls", 5400}},
+			3.0 / 5,
+		},
+		"one title missing": {
+			Signature{{"00001.mpls", 100}, {"00002.mpls", 200}, {"00800.mpls", 5400}},
+			3.0 / 4,
+		},
+		"one title added": {
+			Signature{{"00001.mpls", 100}, {"00002.mpls", 200}, {"00003.mpls", 300}, {"00004.mpls", 400}, {"00800.mpls", 5400}},
+			4.0 / 5,
+		},
+		"renumbered": {
+			Signature{{"00011.mpls", 100}, {"00012.mpls", 200}, {"00013.mpls", 300}, {"00810.mpls", 5400}},
+			0,
+		},
+		"empty": {nil, 0},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := base.Similarity(tt.other); got != tt.want {
+				t.Errorf("got %f, want %f", got, tt.want)
+			}
+			if got := tt.other.Similarity(base); got != tt.want {
+				t.Errorf("got %f in reverse, want %f", got, tt.want)
+			}
+		})
+	}
+}
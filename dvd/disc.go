@@ -0,0 +1,193 @@
+package dvd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/achernya/autorip/discid"
+)
+
+// Disc is the parsed contents of a VIDEO_TS directory.
+type Disc struct {
+	// VolumeName is the volume label of the disc. For a mounted
+	// disc, this is the name of the mount point.
+	VolumeName string
+	Manager    *VideoManager
+	// TitleSets are ordered by number, starting at 1.
+	TitleSets []*TitleSet
+}
+
+// Open reads the VIDEO_TS directory of a mounted disc or a copy of
+// one. root may either be the VIDEO_TS directory itself, or its
+// parent.
+func Open(root string) (*Disc, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Base(root), "VIDEO_TS") {
+		root = filepath.Dir(root)
+	}
+	return Read(os.DirFS(root), filepath.Base(root))
+}
+
+// readFile reads a file of the VIDEO_TS directory. Some systems mount
+// discs with lowercase filenames, so both cases are tried.
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	b, err := fs.ReadFile(fsys, path.Join("VIDEO_TS", name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fs.ReadFile(fsys, path.Join("video_ts", strings.ToLower(name)))
+	}
+	return b, err
+}
+
+// Read reads the VIDEO_TS directory at the root of fsys.
+func Read(fsys fs.FS, volumeName string) (*Disc, error) {
+	b, err := readFile(fsys, "VIDEO_TS.IFO")
+	if err != nil {
+		return nil, err
+	}
+	manager, err := ParseVideoManager(b)
+	if err != nil {
+		return nil, err
+	}
+	result := &Disc{
+		VolumeName: volumeName,
+		Manager:    manager,
+	}
+	for number := 1; number <= manager.TitleSets; number++ {
+		name := fmt.Sprintf("VTS_%02d_0.IFO", number)
+		b, err := readFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		titleSet, err := ParseTitleSet(name, b)
+		if err != nil {
+			return nil, err
+		}
+		result.TitleSets = append(result.TitleSets, titleSet)
+	}
+	return result, nil
+}
+
+// TitleInfo is a title of the disc, with everything needed to
+// describe it gathered from its title set.
+type TitleInfo struct {
+	// Number is the number of the title on the disc, starting at 1.
+	Number int
+	// Name identifies the title set and title within it, e.g.,
+	// "VTS_01_0.IFO:2" is the second title of the first title
+	// set.
+	Name     string
+	Duration time.Duration
+	Chapters int
+	Angles   int
+	// Size is the number of bytes of the first angle of the title.
+	Size int64
+	// ProgramChains are the numbers of the program chains the
+	// title plays, in order.
+	ProgramChains []int
+	Streams       []Stream
+}
+
+// Segments returns the program chains of the title as a
+// comma-separated list, like makemkvcon's SegmentsMap.
+func (t *TitleInfo) Segments() string {
+	segments := make([]string, 0, len(t.ProgramChains))
+	for _, pgc := range t.ProgramChains {
+		segments = append(segments, strconv.Itoa(pgc))
+	}
+	return strings.Join(segments, ",")
+}
+
+// Titles returns the titles that are at least minLength long.
+func (d *Disc) Titles(minLength time.Duration) ([]*TitleInfo, error) {
+	result := make([]*TitleInfo, 0)
+	for index, title := range d.Manager.Titles {
+		info, err := d.title(index+1, title)
+		if err != nil {
+			return nil, err
+		}
+		if info.Duration < minLength {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func (d *Disc) title(number int, title Title) (*TitleInfo, error) {
+	if title.TitleSet < 1 || title.TitleSet > len(d.TitleSets) {
+		return nil, fmt.Errorf("title %d is in title set %d, but the disc has %d", number, title.TitleSet, len(d.TitleSets))
+	}
+	titleSet := d.TitleSets[title.TitleSet-1]
+	if title.TitleSetTitle < 1 || title.TitleSetTitle > len(titleSet.Parts) {
+		return nil, fmt.Errorf("title %d is title %d of title set %d, which has %d", number, title.TitleSetTitle, title.TitleSet, len(titleSet.Parts))
+	}
+	result := &TitleInfo{
+		Number:   number,
+		Name:     fmt.Sprintf("VTS_%02d_0.IFO:%d", title.TitleSet, title.TitleSetTitle),
+		Chapters: title.Chapters,
+		Angles:   title.Angles,
+	}
+	for _, part := range titleSet.Parts[title.TitleSetTitle-1] {
+		if part.ProgramChain < 1 || part.ProgramChain > len(titleSet.ProgramChains) {
+			return nil, fmt.Errorf("title %d refers to program chain %d, but its title set has %d", number, part.ProgramChain, len(titleSet.ProgramChains))
+		}
+		if slices.Contains(result.ProgramChains, part.ProgramChain) {
+			continue
+		}
+		result.ProgramChains = append(result.ProgramChains, part.ProgramChain)
+		pgc := titleSet.ProgramChains[part.ProgramChain-1]
+		result.Duration += pgc.Duration
+		for _, cell := range pgc.Cells {
+			if !cell.AngleBlock || cell.FirstAngle {
+				result.Size += cell.Sectors() * sectorSize
+			}
+		}
+	}
+	result.Streams = append(result.Streams, titleSet.Video)
+	if len(result.ProgramChains) > 0 {
+		pgc := titleSet.ProgramChains[result.ProgramChains[0]-1]
+		for _, index := range pgc.Audio {
+			if index < len(titleSet.Audio) {
+				result.Streams = append(result.Streams, titleSet.Audio[index])
+			}
+		}
+		for _, index := range pgc.Subpictures {
+			if index < len(titleSet.Subpictures) {
+				result.Streams = append(result.Streams, titleSet.Subpictures[index])
+			}
+		}
+	}
+	return result, nil
+}
+
+// DiscID returns the disc as used for fingerprinting, with the titles
+// that are at least minLength long.
+func (d *Disc) DiscID(minLength time.Duration) (*discid.Disc, error) {
+	titles, err := d.Titles(minLength)
+	if err != nil {
+		return nil, err
+	}
+	result := &discid.Disc{
+		Name:   d.VolumeName,
+		Titles: make([]*discid.Title, 0, len(titles)),
+	}
+	for _, title := range titles {
+		result.Titles = append(result.Titles, &discid.Title{
+			Filename: title.Name,
+			Size:     title.Size,
+			Duration: discid.FormatDuration(title.Duration),
+		})
+	}
+	return result, nil
+}
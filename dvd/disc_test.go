@@ -0,0 +1,115 @@
+package dvd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/achernya/autorip/discid"
+)
+
+// syntheticDisc returns a VIDEO_TS tree with a main feature in the
+// first title set, and a short logo and a featurette in the second.
+func syntheticDisc() fstest.MapFS {
+	extras := &TitleSet{
+		Parts: [][]Part{
+			{{ProgramChain: 1, Program: 1}},
+			{{ProgramChain: 2, Program: 1}},
+		},
+		ProgramChains: []*ProgramChain{
+			{Duration: time.Minute, Programs: 1, Cells: []Cell{{Duration: time.Minute, FirstSector: 0, LastSector: 99}}},
+			{Duration: 5 * time.Minute, Programs: 1, Cells: []Cell{{Duration: 5 * time.Minute, FirstSector: 100, LastSector: 599}}},
+		},
+	}
+	return fstest.MapFS{
+		"VIDEO_TS/VIDEO_TS.IFO": {Data: videoManager(2, []Title{
+			{Angles: 2, Chapters: 3, TitleSet: 1, TitleSetTitle: 1},
+			{Angles: 1, Chapters: 1, TitleSet: 2, TitleSetTitle: 1},
+			{Angles: 1, Chapters: 1, TitleSet: 2, TitleSetTitle: 2},
+		})},
+		"VIDEO_TS/VTS_01_0.IFO": {Data: titleSet(feature(), [][]byte{audio("en", 6, ExtensionNormal), audio("en", 2, ExtensionDirectorsComment)}, [][]byte{subpicture("en", SubpictureExtensionNormal)})},
+		"VIDEO_TS/VTS_02_0.IFO": {Data: titleSet(extras, [][]byte{audio("en", 2, ExtensionNormal)}, nil)},
+	}
+}
+
+func TestTitles(t *testing.T) {
+	disc, err := Read(syntheticDisc(), "FILM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles, err := disc.Titles(2 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 2 {
+		t.Fatalf("got %d titles, want 2", len(titles))
+	}
+	main := titles[0]
+	if main.Number != 1 || main.Name != "VTS_01_0.IFO:1" || main.Duration != 90*time.Minute || main.Chapters != 3 || main.Angles != 2 {
+		t.Errorf("got %+v, want the 90 minute title 1 with 3 chapters and 2 angles", main)
+	}
+	// Only the first angle is counted.
+	if main.Size != 2500*sectorSize {
+		t.Errorf("got size %d, want %d", main.Size, 2500*sectorSize)
+	}
+	if segments := main.Segments(); segments != "1,2" {
+		t.Errorf("got segments %s, want 1,2", segments)
+	}
+	types := []string{}
+	for _, stream := range main.Streams {
+		types = append(types, stream.Type+"/"+stream.LangCode)
+	}
+	if want := []string{"Video/", "Audio/eng", "Audio/eng", "Subtitles/eng"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("got streams %v, want %v", types, want)
+	}
+	if titles[1].Number != 3 || titles[1].Name != "VTS_02_0.IFO:2" || titles[1].Duration != 5*time.Minute {
+		t.Errorf("got %+v, want the 5 minute title 3", titles[1])
+	}
+
+	want := &discid.Disc{
+		Name: "FILM",
+		Titles: []*discid.Title{
+			{Filename: "VTS_01_0.IFO:1", Size: 2500 * sectorSize, Duration: "1:30:00"},
+			{Filename: "VTS_02_0.IFO:2", Size: 500 * sectorSize, Duration: "0:05:00"},
+		},
+	}
+	if got, err := disc.DiscID(2 * time.Minute); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v (%v), want %+v", got, err, want)
+	}
+}
+
+func TestTitlesInvalidReference(t *testing.T) {
+	fsys := syntheticDisc()
+	fsys["VIDEO_TS/VIDEO_TS.IFO"] = &fstest.MapFile{Data: videoManager(1, []Title{{TitleSet: 1, TitleSetTitle: 2}})}
+	disc, err := Read(fsys, "FILM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := disc.Titles(0); err == nil {
+		t.Error("Titles unexpectedly succeeded with a title that does not exist")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "FILM")
+	lowercase := fstest.MapFS{}
+	for name, file := range syntheticDisc() {
+		lowercase[strings.ToLower(name)] = file
+	}
+	if err := os.CopyFS(root, lowercase); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{root, filepath.Join(root, "video_ts")} {
+		disc, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if disc.VolumeName != "FILM" || len(disc.TitleSets) != 2 {
+			t.Errorf("Open(%s) got %s with %d title sets, want FILM with 2", dir, disc.VolumeName, len(disc.TitleSets))
+		}
+	}
+}
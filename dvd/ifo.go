@@ -0,0 +1,335 @@
+// Package dvd parses the IFO files of a DVD-Video disc's VIDEO_TS
+// directory, so that a disc can be analyzed without makemkvcon.
+//
+// The video manager (VIDEO_TS.IFO) lists the titles of the disc, and
+// which title set (VTS_xx_0.IFO) each of them is in. Each title set
+// describes the streams shared by its titles, and the program chains
+// (PGCs) that play them. All structures are big-endian, and
+// addresses are either in bytes or in 2048-byte sectors.
+package dvd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const sectorSize = 2048
+
+// Stream types, named the same as makemkvcon names them.
+const (
+	Video     = "Video"
+	Audio     = "Audio"
+	Subtitles = "Subtitles"
+)
+
+// reader is a bounds-checked big-endian reader for structures at
+// fixed offsets. The first out of bounds access is remembered in
+// err, and every following access returns zero values.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(off, n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if off < 0 || n < 0 || off+n > len(r.b) {
+		r.err = fmt.Errorf("unexpected end of data at offset %d (want %d bytes of %d)", off, n, len(r.b))
+		return make([]byte, n)
+	}
+	return r.b[off : off+n]
+}
+
+func (r *reader) u8(off int) uint8   { return r.bytes(off, 1)[0] }
+func (r *reader) u16(off int) uint16 { return binary.BigEndian.Uint16(r.bytes(off, 2)) }
+func (r *reader) u32(off int) uint32 { return binary.BigEndian.Uint32(r.bytes(off, 4)) }
+
+func (r *reader) header(magic string) {
+	if got := string(r.bytes(0, len(magic))); r.err == nil && got != magic {
+		r.err = fmt.Errorf("got identifier %+q, want %+q", got, magic)
+	}
+}
+
+// Title is an entry of the video manager's title search pointer table.
+type Title struct {
+	Angles   int
+	Chapters int
+	// TitleSet is the number of the title set the title is in,
+	// and TitleSetTitle the number of the title within it. Both
+	// start at 1.
+	TitleSet      int
+	TitleSetTitle int
+}
+
+// VideoManager is a parsed VIDEO_TS.IFO.
+type VideoManager struct {
+	TitleSets int
+	Titles    []Title
+}
+
+// ParseVideoManager parses the contents of VIDEO_TS.IFO.
+func ParseVideoManager(b []byte) (*VideoManager, error) {
+	r := &reader{b: b}
+	r.header("DVDVIDEO-VMG")
+	result := &VideoManager{TitleSets: int(r.u16(0x3e))}
+	srpt := int(r.u32(0xc4)) * sectorSize
+	titles := int(r.u16(srpt))
+	for index := range titles {
+		entry := srpt + 8 + 12*index
+		result.Titles = append(result.Titles, Title{
+			Angles:        int(r.u8(entry + 1)),
+			Chapters:      int(r.u16(entry + 2)),
+			TitleSet:      int(r.u8(entry + 6)),
+			TitleSetTitle: int(r.u8(entry + 7)),
+		})
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("VIDEO_TS.IFO: %w", r.err)
+	}
+	return result, nil
+}
+
+// Stream describes an audio or subpicture stream of a title set, or
+// its single video stream.
+type Stream struct {
+	Type       string
+	CodecID    string
+	CodecShort string
+	// LangCode is the ISO 639-2 code of the language, if the
+	// disc specifies one.
+	LangCode string
+	// Extension is the code extension of audio and subpicture
+	// streams, e.g., whether they are commentary. Audio and
+	// subpicture streams use different codes.
+	Extension uint8
+	// VideoSize and AspectRatio are only set for video streams.
+	VideoSize   string
+	AspectRatio string
+	// Channels and SampleRate are only set for audio streams.
+	Channels   int
+	SampleRate int
+}
+
+// Audio code extensions.
+const (
+	ExtensionNormal           = 1
+	ExtensionVisuallyImpaired = 2
+	ExtensionDirectorsComment = 3
+	ExtensionAlternateComment = 4
+)
+
+// Subpicture code extensions.
+const (
+	SubpictureExtensionNormal                    = 1
+	SubpictureExtensionLarge                     = 2
+	SubpictureExtensionChildrens                 = 3
+	SubpictureExtensionNormalCaptions            = 5
+	SubpictureExtensionLargeCaptions             = 6
+	SubpictureExtensionChildrensCaptions         = 7
+	SubpictureExtensionForced                    = 9
+	SubpictureExtensionDirectorsComment          = 13
+	SubpictureExtensionLargeDirectorsComment     = 14
+	SubpictureExtensionChildrensDirectorsComment = 15
+)
+
+// Cell is a single contiguous section of a program chain.
+type Cell struct {
+	Duration time.Duration
+	// FirstSector and LastSector are relative to the start of the
+	// title set's VOBs.
+	FirstSector uint32
+	LastSector  uint32
+	// AngleBlock is set for cells that are one of several angles.
+	// Only the first angle of each block has FirstAngle set.
+	AngleBlock bool
+	FirstAngle bool
+}
+
+// Sectors returns the number of sectors in the cell.
+func (c *Cell) Sectors() int64 {
+	if c.LastSector < c.FirstSector {
+		return 0
+	}
+	return int64(c.LastSector-c.FirstSector) + 1
+}
+
+// ProgramChain is a parsed PGC, a sequence of cells played in order.
+type ProgramChain struct {
+	Duration time.Duration
+	Programs int
+	Cells    []Cell
+	// Audio and Subpictures are the indices of the title set's
+	// streams that are available in the program chain.
+	Audio       []int
+	Subpictures []int
+}
+
+// Part is an entry of the part-of-title search pointer table, i.e.,
+// a chapter.
+type Part struct {
+	// ProgramChain and Program start at 1.
+	ProgramChain int
+	Program      int
+}
+
+// TitleSet is a parsed VTS_xx_0.IFO.
+type TitleSet struct {
+	Video       Stream
+	Audio       []Stream
+	Subpictures []Stream
+	// Parts are the chapters of each title of the title set.
+	Parts         [][]Part
+	ProgramChains []*ProgramChain
+}
+
+// ParseTitleSet parses the contents of a VTS_xx_0.IFO.
+func ParseTitleSet(name string, b []byte) (*TitleSet, error) {
+	r := &reader{b: b}
+	r.header("DVDVIDEO-VTS")
+	result := &TitleSet{Video: videoAttributes(r.u16(0x200))}
+	for index := range min(int(r.u16(0x202)), 8) {
+		result.Audio = append(result.Audio, audioAttributes(r.bytes(0x204+8*index, 8)))
+	}
+	for index := range min(int(r.u16(0x254)), 32) {
+		result.Subpictures = append(result.Subpictures, subpictureAttributes(r.bytes(0x256+6*index, 6)))
+	}
+
+	ptt := int(r.u32(0xc8)) * sectorSize
+	titles := int(r.u16(ptt))
+	end := ptt + int(r.u32(ptt+4)) + 1
+	for index := range titles {
+		start := ptt + int(r.u32(ptt+8+4*index))
+		next := end
+		if index+1 < titles {
+			next = ptt + int(r.u32(ptt+8+4*(index+1)))
+		}
+		parts := make([]Part, 0, (next-start)/4)
+		for off := start; off+4 <= next; off += 4 {
+			parts = append(parts, Part{ProgramChain: int(r.u16(off)), Program: int(r.u16(off + 2))})
+		}
+		result.Parts = append(result.Parts, parts)
+	}
+
+	pgci := int(r.u32(0xcc)) * sectorSize
+	for index := range int(r.u16(pgci)) {
+		pgc := pgci + int(r.u32(pgci+8+8*index+4))
+		result.ProgramChains = append(result.ProgramChains, r.programChain(pgc))
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", name, r.err)
+	}
+	return result, nil
+}
+
+func (r *reader) programChain(pgc int) *ProgramChain {
+	result := &ProgramChain{
+		Programs: int(r.u8(pgc + 2)),
+		Duration: playbackTime(r.bytes(pgc+4, 4)),
+	}
+	for index := range 8 {
+		if r.u16(pgc+0x0c+2*index)&0x8000 != 0 {
+			result.Audio = append(result.Audio, index)
+		}
+	}
+	for index := range 32 {
+		if r.u32(pgc+0x1c+4*index)&0x80000000 != 0 {
+			result.Subpictures = append(result.Subpictures, index)
+		}
+	}
+	cells := int(r.u8(pgc + 3))
+	playback := pgc + int(r.u16(pgc+0xe8))
+	for index := range cells {
+		cell := playback + 24*index
+		category := r.u8(cell)
+		result.Cells = append(result.Cells, Cell{
+			Duration:    playbackTime(r.bytes(cell+4, 4)),
+			FirstSector: r.u32(cell + 8),
+			LastSector:  r.u32(cell + 20),
+			AngleBlock:  category&0x30 == 0x10,
+			FirstAngle:  category&0xc0 == 0x40,
+		})
+	}
+	return result
+}
+
+// playbackTime decodes a BCD hh:mm:ss:ff playback time. The top two
+// bits of the frames are the frame rate.
+func playbackTime(b []byte) time.Duration {
+	bcd := func(v byte) int {
+		return int(v>>4)*10 + int(v&0xf)
+	}
+	result := time.Duration(bcd(b[0]))*time.Hour + time.Duration(bcd(b[1]))*time.Minute + time.Duration(bcd(b[2]))*time.Second
+	fps := 30
+	if b[3]>>6 == 1 {
+		fps = 25
+	}
+	return result + time.Duration(bcd(b[3]&0x3f))*time.Second/time.Duration(fps)
+}
+
+var (
+	audioCodecs = map[uint8][2]string{
+		0: {"A_AC3", "DD"},
+		2: {"A_MPEG/L2", "MP2"},
+		3: {"A_MPEG/L2", "MP2"},
+		4: {"A_LPCM", "LPCM"},
+		6: {"A_DTS", "DTS"},
+	}
+	sampleRates = map[uint8]int{0: 48000, 1: 96000}
+)
+
+func videoAttributes(v uint16) Stream {
+	result := Stream{Type: Video, CodecID: "V_MPEG2", CodecShort: "Mpeg2"}
+	if v>>14 == 0 {
+		result.CodecID, result.CodecShort = "V_MPEG1", "Mpeg1"
+	}
+	height := 480
+	if (v>>12)&3 == 1 {
+		height = 576
+	}
+	switch (v >> 3) & 7 {
+	case 0:
+		result.VideoSize = fmt.Sprintf("720x%d", height)
+	case 1:
+		result.VideoSize = fmt.Sprintf("704x%d", height)
+	case 2:
+		result.VideoSize = fmt.Sprintf("352x%d", height)
+	case 3:
+		result.VideoSize = fmt.Sprintf("352x%d", height/2)
+	}
+	result.AspectRatio = "4:3"
+	if (v>>10)&3 == 3 {
+		result.AspectRatio = "16:9"
+	}
+	return result
+}
+
+func audioAttributes(b []byte) Stream {
+	codec := audioCodecs[b[0]>>5]
+	result := Stream{
+		Type:       Audio,
+		CodecID:    codec[0],
+		CodecShort: codec[1],
+		Channels:   int(b[1]&7) + 1,
+		SampleRate: sampleRates[(b[1]>>4)&3],
+		Extension:  b[5],
+	}
+	if (b[0]>>2)&3 == 1 {
+		result.LangCode = languageCode(b[2:4])
+	}
+	return result
+}
+
+func subpictureAttributes(b []byte) Stream {
+	result := Stream{
+		Type:       Subtitles,
+		CodecID:    "S_VOBSUB",
+		CodecShort: "VobSub",
+		Extension:  b[5],
+	}
+	if b[0]&3 == 1 {
+		result.LangCode = languageCode(b[2:4])
+	}
+	return result
+}
@@ -0,0 +1,233 @@
+package dvd
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// image builds a synthetic IFO file, which is a sequence of sectors.
+type image struct {
+	b []byte
+}
+
+func newImage(sectors int) *image {
+	return &image{b: make([]byte, sectors*sectorSize)}
+}
+
+func (i *image) u8(off int, v uint8)   { i.b[off] = v }
+func (i *image) u16(off int, v uint16) { binary.BigEndian.PutUint16(i.b[off:], v) }
+func (i *image) u32(off int, v uint32) { binary.BigEndian.PutUint32(i.b[off:], v) }
+func (i *image) str(off int, v string) { copy(i.b[off:], v) }
+
+// bcd encodes a duration as a BCD playback time at 30 frames per
+// second.
+func bcd(d time.Duration) []byte {
+	enc := func(v int) byte {
+		return byte(v/10<<4 | v%10)
+	}
+	return []byte{enc(int(d.Hours())), enc(int(d.Minutes()) % 60), enc(int(d.Seconds()) % 60), 0xc0}
+}
+
+// videoManager returns a VIDEO_TS.IFO for the given titles.
+func videoManager(titleSets int, titles []Title) []byte {
+	i := newImage(2)
+	i.str(0, "DVDVIDEO-VMG")
+	i.u16(0x3e, uint16(titleSets))
+	i.u32(0xc4, 1)
+	srpt := sectorSize
+	i.u16(srpt, uint16(len(titles)))
+	for index, title := range titles {
+		entry := srpt + 8 + 12*index
+		i.u8(entry+1, uint8(title.Angles))
+		i.u16(entry+2, uint16(title.Chapters))
+		i.u8(entry+6, uint8(title.TitleSet))
+		i.u8(entry+7, uint8(title.TitleSetTitle))
+	}
+	return i.b
+}
+
+// audio returns the attributes of an AC3 audio stream.
+func audio(lang string, channels int, extension uint8) []byte {
+	return []byte{1 << 2, uint8(channels - 1), lang[0], lang[1], 0, extension, 0, 0}
+}
+
+// subpicture returns the attributes of a subpicture stream.
+func subpicture(lang string, extension uint8) []byte {
+	return []byte{1, 0, lang[0], lang[1], 0, extension}
+}
+
+// titleSet returns a VTS_xx_0.IFO for the given title set. Every
+// audio and subpicture stream is available in every program chain.
+func titleSet(ts *TitleSet, audioAttributes [][]byte, subpictures [][]byte) []byte {
+	i := newImage(3 + len(ts.ProgramChains))
+	i.str(0, "DVDVIDEO-VTS")
+	i.u32(0xc8, 1)
+	i.u32(0xcc, 2)
+	i.u16(0x200, 0x4c00) // MPEG-2, NTSC, 16:9, 720x480
+	i.u16(0x202, uint16(len(audioAttributes)))
+	for index, attributes := range audioAttributes {
+		copy(i.b[0x204+8*index:], attributes)
+	}
+	i.u16(0x254, uint16(len(subpictures)))
+	for index, attributes := range subpictures {
+		copy(i.b[0x256+6*index:], attributes)
+	}
+
+	ptt := sectorSize
+	i.u16(ptt, uint16(len(ts.Parts)))
+	off := 8 + 4*len(ts.Parts)
+	for index, parts := range ts.Parts {
+		i.u32(ptt+8+4*index, uint32(off))
+		for _, part := range parts {
+			i.u16(ptt+off, uint16(part.ProgramChain))
+			i.u16(ptt+off+2, uint16(part.Program))
+			off += 4
+		}
+	}
+	i.u32(ptt+4, uint32(off-1))
+
+	pgci := 2 * sectorSize
+	i.u16(pgci, uint16(len(ts.ProgramChains)))
+	for index, pgc := range ts.ProgramChains {
+		// Each program chain is in a sector of its own.
+		i.u32(pgci+8+8*index+4, uint32((index+1)*sectorSize))
+		start := pgci + (index+1)*sectorSize
+		i.u8(start+2, uint8(pgc.Programs))
+		i.u8(start+3, uint8(len(pgc.Cells)))
+		copy(i.b[start+4:], bcd(pgc.Duration))
+		for index := range audioAttributes {
+			i.u16(start+0x0c+2*index, 0x8000|uint16(index)<<8)
+		}
+		for index := range subpictures {
+			i.u32(start+0x1c+4*index, 0x80000000|uint32(index))
+		}
+		i.u16(start+0xe8, 0xec)
+		for index, cell := range pgc.Cells {
+			off := start + 0xec + 24*index
+			if cell.AngleBlock {
+				category := uint8(0x10)
+				if cell.FirstAngle {
+					category |= 0x40
+				}
+				i.u8(off, category)
+			}
+			copy(i.b[off+4:], bcd(cell.Duration))
+			i.u32(off+8, cell.FirstSector)
+			i.u32(off+20, cell.LastSector)
+		}
+	}
+	return i.b
+}
+
+// feature is a title set with a single title, played by two program
+// chains. The first program chain has two angles in the middle.
+func feature() *TitleSet {
+	return &TitleSet{
+		Parts: [][]Part{{
+			{ProgramChain: 1, Program: 1},
+			{ProgramChain: 1, Program: 2},
+			{ProgramChain: 2, Program: 1},
+		}},
+		ProgramChains: []*ProgramChain{
+			{
+				Duration: time.Hour,
+				Programs: 2,
+				Cells: []Cell{
+					{Duration: 40 * time.Minute, FirstSector: 0, LastSector: 999},
+					{Duration: 20 * time.Minute, FirstSector: 1000, LastSector: 1499, AngleBlock: true, FirstAngle: true},
+					{Duration: 20 * time.Minute, FirstSector: 1500, LastSector: 1999, AngleBlock: true},
+				},
+			},
+			{
+				Duration: 30 * time.Minute,
+				Programs: 1,
+				Cells: []Cell{
+					{Duration: 30 * time.Minute, FirstSector: 2000, LastSector: 2999},
+				},
+			},
+		},
+	}
+}
+
+func TestParseVideoManager(t *testing.T) {
+	titles := []Title{
+		{Angles: 2, Chapters: 3, TitleSet: 1, TitleSetTitle: 1},
+		{Angles: 1, Chapters: 1, TitleSet: 2, TitleSetTitle: 1},
+	}
+	got, err := ParseVideoManager(videoManager(2, titles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &VideoManager{TitleSets: 2, Titles: titles}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseTitleSet(t *testing.T) {
+	want := feature()
+	got, err := ParseTitleSet("VTS_01_0.IFO", titleSet(want, [][]byte{audio("en", 6, ExtensionNormal), audio("fr", 2, ExtensionDirectorsComment)},
+		[][]byte{subpicture("en", SubpictureExtensionNormal), subpicture("xx", SubpictureExtensionForced), subpicture("fr", SubpictureExtensionDirectorsComment)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Parts, want.Parts) {
+		t.Errorf("got parts %+v, want %+v", got.Parts, want.Parts)
+	}
+	if len(got.ProgramChains) != 2 {
+		t.Fatalf("got %d program chains, want 2", len(got.ProgramChains))
+	}
+	for index, pgc := range got.ProgramChains {
+		if pgc.Duration != want.ProgramChains[index].Duration || !reflect.DeepEqual(pgc.Cells, want.ProgramChains[index].Cells) {
+			t.Errorf("got program chain %d %+v, want %+v", index, pgc, want.ProgramChains[index])
+		}
+		if !reflect.DeepEqual(pgc.Audio, []int{0, 1}) || !reflect.DeepEqual(pgc.Subpictures, []int{0, 1, 2}) {
+			t.Errorf("got program chain %d streams %v and %v, want all of them", index, pgc.Audio, pgc.Subpictures)
+		}
+	}
+	wantStreams := []Stream{
+		{Type: Video, CodecID: "V_MPEG2", CodecShort: "Mpeg2", VideoSize: "720x480", AspectRatio: "16:9"},
+		{Type: Audio, CodecID: "A_AC3", CodecShort: "DD", LangCode: "eng", Channels: 6, SampleRate: 48000, Extension: ExtensionNormal},
+		{Type: Audio, CodecID: "A_AC3", CodecShort: "DD", LangCode: "fre", Channels: 2, SampleRate: 48000, Extension: ExtensionDirectorsComment},
+		{Type: Subtitles, CodecID: "S_VOBSUB", CodecShort: "VobSub", LangCode: "eng", Extension: SubpictureExtensionNormal},
+		{Type: Subtitles, CodecID: "S_VOBSUB", CodecShort: "VobSub", LangCode: "xx", Extension: SubpictureExtensionForced},
+		{Type: Subtitles, CodecID: "S_VOBSUB", CodecShort: "VobSub", LangCode: "fre", Extension: SubpictureExtensionDirectorsComment},
+	}
+	gotStreams := append(append([]Stream{got.Video}, got.Audio...), got.Subpictures...)
+	if !reflect.DeepEqual(gotStreams, wantStreams) {
+		t.Errorf("got streams %+v, want %+v", gotStreams, wantStreams)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	vmg := videoManager(1, []Title{{TitleSet: 1, TitleSetTitle: 1}})
+	if _, err := ParseVideoManager(vmg[:sectorSize]); err == nil {
+		t.Error("ParseVideoManager unexpectedly succeeded without a title table")
+	}
+	if _, err := ParseVideoManager(append([]byte("DVDVIDEO-VTS"), vmg[12:]...)); err == nil {
+		t.Error("ParseVideoManager unexpectedly succeeded on a title set")
+	}
+	vts := titleSet(feature(), nil, nil)
+	if _, err := ParseTitleSet("VTS_01_0.IFO", vts[:2*sectorSize]); err == nil {
+		t.Error("ParseTitleSet unexpectedly succeeded without program chains")
+	}
+}
+
+func TestPlaybackTime(t *testing.T) {
+	tests := map[string]struct {
+		input []byte
+		want  time.Duration
+	}{
+		"30 fps": {[]byte{0x01, 0x23, 0x45, 0xc0 | 0x15}, time.Hour + 23*time.Minute + 45*time.Second + 500*time.Millisecond},
+		"25 fps": {[]byte{0x00, 0x00, 0x10, 0x40 | 0x05}, 10*time.Second + 200*time.Millisecond},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := playbackTime(tt.input); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package dvd
+
+import "strings"
+
+// languages maps the ISO 639-1 codes DVDs use to the ISO 639-2 codes
+// makemkvcon (and Blu-rays) use.
+var languages = map[string]string{
+	"ar": "ara", "bg": "bul", "ca": "cat", "cs": "cze", "da": "dan",
+	"de": "ger", "el": "gre", "en": "eng", "es": "spa", "et": "est",
+	"fa": "per", "fi": "fin", "fr": "fre", "he": "heb", "hi": "hin",
+	"hr": "hrv", "hu": "hun", "id": "ind", "is": "ice", "it": "ita",
+	"ja": "jpn", "ko": "kor", "lt": "lit", "lv": "lav", "ms": "may",
+	"nl": "dut", "no": "nor", "pl": "pol", "pt": "por", "ro": "rum",
+	"ru": "rus", "sk": "slo", "sl": "slv", "sr": "srp", "sv": "swe",
+	"th": "tha", "tr": "tur", "uk": "ukr", "vi": "vie", "zh": "chi",
+}
+
+// languageCode converts a 2-byte ISO 639-1 code into ISO 639-2. Codes
+// without a known equivalent are returned as they are.
+func languageCode(b []byte) string {
+	code := strings.ToLower(strings.TrimRight(string(b), "\x00 "))
+	if long, ok := languages[code]; ok {
+		return long
+	}
+	return code
+}
@@ -4,7 +4,6 @@ go 1.24.4
 
 require (
 	github.com/blevesearch/bleve/v2 v2.5.2
-	github.com/cavaliergopher/grab/v3 v3.0.1
 	github.com/charmbracelet/bubbles v0.21.0
 	github.com/charmbracelet/bubbletea v1.3.5
 	github.com/charmbracelet/fang v0.2.0
@@ -5,6 +5,7 @@ import (
 	"cmp"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -110,35 +111,61 @@ func episodesSort(a, b *pb.Title) int {
 	return cmp.Compare(a.GetSeasonNumber(), b.GetSeasonNumber())
 }
 
+// ErrNotFound is returned by Lookup if the title is not in the index.
+var ErrNotFound = errors.New("title not found")
+
 // GenericIndex is an interface that Index satisfies. It primarily
 // exists to allow mocking. Prefer to accept GenericIndex rather than
 // the concrete implementation Index, below.
 type GenericIndex interface {
 	Build() error
 	Search(ctx context.Context, query string) (<-chan *pb.Result, error)
+	Query(ctx context.Context, query *TitleQuery) (<-chan *pb.Result, error)
 	SearchJSON(query string, maxResults int) (string, error)
+	Lookup(ctx context.Context, tconst string) (*pb.Title, error)
+	RuntimeDistributions() (map[string]*RuntimeDistribution, error)
+	Snapshot() (*Snapshot, error)
 	Close()
 }
 
 // Index is a LevelDB and Blevesearch index for the IMDB data.
 type Index struct {
-	dir   string
-	ldb   *leveldb.DB
-	index bleve.Index
+	// dir is where the LevelDB and Blevesearch indexes live.
+	dir string
+	// datasets is where the IMDb datasets are read from while
+	// building the index.
+	datasets string
+	ldb      *leveldb.DB
+	index    bleve.Index
 }
 
-func (i *Index) openLevelDb(read bool) error {
+type levelDbMode int
+
+const (
+	// levelDbCreate expects to create a new database.
+	levelDbCreate levelDbMode = iota
+	// levelDbRead opens an existing database read-only.
+	levelDbRead
+	// levelDbResume opens an existing database for writing.
+	levelDbResume
+)
+
+func (i *Index) openLevelDb(mode levelDbMode) error {
 	options := &opt.Options{
 		// Ideally we'd use zstd here, but it's not available.
 		Compression: opt.SnappyCompression,
 	}
-	if read {
+	switch mode {
+	case levelDbRead:
 		// Database should already exist
 		options.ErrorIfExist = false
 		options.ErrorIfMissing = true
 		// We won't do any writes here.
 		options.ReadOnly = true
-	} else {
+	case levelDbResume:
+		options.ErrorIfExist = false
+		options.ErrorIfMissing = true
+	default:
 		// We expect to create the database here.
 		options.ErrorIfExist = true
 		options.ErrorIfMissing = false
@@ -151,36 +178,53 @@ func (i *Index) openLevelDb(read bool) error {
 	return nil
 }
 
+func (i *Index) newBleve() error {
+	mapping := bleve.NewIndexMapping()
+	mapping.DefaultField = "Title"
+	mapping.TypeField = "type"
+	mapping.DefaultAnalyzer = "en"
+	mapping.ScoringModel = "bm25"
+	mapping.DefaultMapping = newSearchDocumentMapping()
+	if err := addTitleAnalyzers(mapping); err != nil {
+		return err
+	}
+	index, err := bleve.New(path.Join(i.dir, imdbBleve), mapping)
+	if err != nil {
+		return err
+	}
+	i.index = index
+	return nil
+}
+
 // NewIndex prepares an index for population. If you want to query the
 // index, use OpenIndex instead.
 func NewIndex(dir string) (GenericIndex, error) {
 	idx := &Index{
-		dir: dir,
+		dir:      dir,
+		datasets: dir,
 	}
-	if err := idx.openLevelDb(false); err != nil {
+	if err := idx.openLevelDb(levelDbCreate); err != nil {
 		return nil, err
 	}
-	mapping := bleve.NewIndexMapping()
-	mapping.DefaultField = "Title"
-	mapping.TypeField = "type"
-	mapping.DefaultAnalyzer = "en"
-	mapping.ScoringModel = "bm25"
-	index, err := bleve.New(path.Join(idx.dir, imdbBleve), mapping)
-	if err != nil {
+	if err := idx.newBleve(); err != nil {
 		idx.ldb.Close() //nolint:errcheck
 		return nil, err
 	}
-	idx.index = index
 	return idx, nil
 }
 
 // OpenIndex opens an index for queries. If you want to create a new
-// index, use NewIndex instead.
+// index, use NewIndex instead. If dir contains generations of the
+// index created by Rebuild, the current generation is opened.
 func OpenIndex(dir string) (*Index, error) {
+	dir, err := resolveGeneration(dir)
+	if err != nil {
+		return nil, err
+	}
 	idx := &Index{
 		dir: dir,
 	}
-	if err := idx.openLevelDb(true); err != nil {
+	if err := idx.openLevelDb(levelDbRead); err != nil {
 		return nil, err
 	}
 	index, err := bleve.OpenUsing(path.Join(dir, imdbBleve), map[string]interface{}{
@@ -197,8 +241,9 @@ func OpenIndex(dir string) (*Index, error) {
 
 func (i *Index) findTitle(title string) (*pb.Title, error) {
 	it := i.ldb.NewIterator(&util.Range{Start: key(title), Limit: nil}, nil)
+	defer it.Release()
 	if !it.First() {
-		return nil, fmt.Errorf("could not find key %+v", title)
+		return nil, fmt.Errorf("could not find key %+v: %w", title, ErrNotFound)
 	}
 	var entry *pb.Title = nil
 	var err error
@@ -237,7 +282,7 @@ func (i *Index) findTitle(title string) (*pb.Title, error) {
 	}
 	// Double-check that some data was found.
 	if entry == nil {
-		return nil, fmt.Errorf("could not find key %+v", title)
+		return nil, fmt.Errorf("could not find key %+v: %w", title, ErrNotFound)
 	}
 
 	// Fill in per-episode data
@@ -256,7 +301,7 @@ func (i *Index) findTitle(title string) (*pb.Title, error) {
 }
 
 func (i *Index) loadTitles() error {
-	scanner, err := newImdbTsv(path.Join(i.dir, basics))
+	scanner, err := newImdbTsv(path.Join(i.datasets, basics))
 	if err != nil {
 		return err
 	}
@@ -268,6 +313,7 @@ func (i *Index) loadTitles() error {
 	}
 	count := 0
 	batch := leveldb.Batch{}
+	stats := make(runtimeStats)
 
 	for scanner.scanner.Scan() {
 		line := scanner.scanner.Text()
@@ -289,6 +335,7 @@ func (i *Index) loadTitles() error {
 		}
 		if runtime, err := strconv.Atoi(record[7]); err == nil {
 			title.SetRuntimeMinutes(int32(runtime))
+			stats.add(title.GetTitleType(), title.GetRuntimeMinutes())
 		}
 		b, err := proto.Marshal(title)
 		if err != nil {
@@ -307,11 +354,14 @@ func (i *Index) loadTitles() error {
 	if err := tx.Write(&batch, nil); err != nil {
 		return err
 	}
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return i.writeRuntimes(stats)
 }
 
 func (i *Index) loadEpisodes() error {
-	scanner, err := newImdbTsv(path.Join(i.dir, episodes))
+	scanner, err := newImdbTsv(path.Join(i.datasets, episodes))
 	if err != nil {
 		return err
 	}
@@ -362,7 +412,7 @@ func (i *Index) loadEpisodes() error {
 func (i *Index) makeSearch() error {
 	// NOTE: any content that does not have a rating will not be
 	// indexed by full-text search!
-	scanner, err := newImdbTsv(path.Join(i.dir, ratings))
+	scanner, err := newImdbTsv(path.Join(i.datasets, ratings))
 	if err != nil {
 		return err
 	}
@@ -382,13 +432,7 @@ func (i *Index) makeSearch() error {
 		if err != nil {
 			return err
 		}
-		entry := struct {
-			Title         string
-			AverageRating float32
-			NumVotes      int
-		}{
-			Title: l.GetPrimaryTitle(),
-		}
+		entry := newSearchDocument(l)
 		rating, err := strconv.ParseFloat(record[1], 32)
 		if err != nil {
 			return err
@@ -443,7 +487,7 @@ func (i *Index) Build() error {
 	if err := i.makeSearch(); err != nil {
 		return err
 	}
-	return nil
+	return i.writeSnapshot()
 }
 
 func (i *Index) Search(ctx context.Context, query string) (<-chan *pb.Result, error) {
@@ -453,6 +497,12 @@ func (i *Index) Search(ctx context.Context, query string) (<-chan *pb.Result, er
 	// time and remove this limit, but realistically this is more
 	// than anyone will ever need.
 	searchRequest.Size = 100
+	return i.search(ctx, searchRequest)
+}
+
+// search runs the request, and streams the matching titles in order
+// of descending score and popularity.
+func (i *Index) search(ctx context.Context, searchRequest *bleve.SearchRequest) (<-chan *pb.Result, error) {
 	searchRequest.Fields = []string{"NumVotes", "AverageRating"}
 	searchRequest.SortBy([]string{"-_score", "-NumVotes"})
 	searchResult, err := i.index.Search(searchRequest)
@@ -487,6 +537,16 @@ func (i *Index) Search(ctx context.Context, query string) (<-chan *pb.Result, er
 	return ch, nil
 }
 
+// Lookup returns the title with the given tconst (e.g.,
+// "tt0944947"). If the title is a series, its episodes are included,
+// sorted by season and episode number.
+func (i *Index) Lookup(ctx context.Context, tconst string) (*pb.Title, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return i.findTitle(tconst)
+}
+
 func (i *Index) SearchJSON(query string, maxResults int) (string, error) {
 	results := &pb.Results{}
 	results.SetResult(make([]*pb.Result, 0))
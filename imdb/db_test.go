@@ -2,10 +2,14 @@ package imdb
 
 import (
 	"compress/gzip"
+	"errors"
 	"io"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -137,3 +141,177 @@ func TestPopulateAndQueryIndex(t *testing.T) {
 		t.Errorf("got %+q, want non-empty json", json)
 	}
 }
+
+func TestLookup(t *testing.T) {
+	dir := newTmpDir(t)
+	defer dir.Cleanup()
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	index, err := OpenIndex(dir.dir)
+	if err != nil {
+		t.Fatalf("failed to open existing index %+v", err)
+	}
+	defer index.Close()
+
+	series, err := index.Lookup(t.Context(), "tt0041069")
+	if err != nil {
+		t.Fatalf("failed to look up series: %+v", err)
+	}
+	if series.GetPrimaryTitle() != "The Voice of Firestone" {
+		t.Errorf("got %+q, want %+q", series.GetPrimaryTitle(), "The Voice of Firestone")
+	}
+	want := []string{"tt1563488", "tt1832219"}
+	got := []string{}
+	for _, episode := range series.GetEpisodes() {
+		got = append(got, episode.GetTConst())
+		if episode.GetSeasonNumber() != 1 || episode.GetTitleType() != "tvEpisode" {
+			t.Errorf("incomplete episode %+v", episode)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got episodes %+v, want %+v", got, want)
+	}
+
+	if _, err := index.Lookup(t.Context(), "tt9999999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %+v, want ErrNotFound", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	dir := newTmpDir(t)
+	defer dir.Cleanup()
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	index, err := OpenIndex(dir.dir)
+	if err != nil {
+		t.Fatalf("failed to open existing index %+v", err)
+	}
+	defer index.Close()
+
+	tests := map[string]struct {
+		query *TitleQuery
+		want  []string
+	}{
+		"text only": {
+			query: &TitleQuery{Text: "voice firestone"},
+			want:  []string{"tt0041069"},
+		},
+		"matching type": {
+			query: &TitleQuery{Text: "voice", TitleTypes: []string{"movie", "tvSeries"}},
+			want:  []string{"tt0041069"},
+		},
+		"wrong type": {
+			query: &TitleQuery{Text: "voice", TitleTypes: []string{"movie"}},
+			want:  []string{},
+		},
+		"runtime in range": {
+			query: &TitleQuery{Text: "voice", MinRuntime: 28, MaxRuntime: 32},
+			want:  []string{"tt0041069"},
+		},
+		"runtime out of range": {
+			query: &TitleQuery{Text: "voice", MinRuntime: 90, MaxRuntime: 110},
+			want:  []string{},
+		},
+		"misspelled exact": {
+			query: &TitleQuery{Text: "voise firestome"},
+			want:  []string{},
+		},
+		"misspelled fuzzy": {
+			query: &TitleQuery{Text: "voise firestome", Match: MatchFuzzy},
+			want:  []string{"tt0041069"},
+		},
+		"truncated exact": {
+			query: &TitleQuery{Text: "firest"},
+			want:  []string{},
+		},
+		"truncated prefix": {
+			query: &TitleQuery{Text: "firest", Match: MatchPrefix},
+			want:  []string{"tt0041069"},
+		},
+		"abbreviated prefix": {
+			query: &TitleQuery{Text: "VOF", Match: MatchPrefix},
+			want:  []string{"tt0041069"},
+		},
+		"year does not exclude": {
+			query: &TitleQuery{Text: "carmencita", Year: 2001},
+			want:  []string{"tt0000001"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ch, err := index.Query(t.Context(), tt.query)
+			if err != nil {
+				t.Fatalf("error while performing query: %+v", err)
+			}
+			got := []string{}
+			for result := range ch {
+				got = append(got, result.GetEntry().GetTConst())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitials(t *testing.T) {
+	got := initials("The Lord of the Rings: The Fellowship of the Ring")
+	for _, want := range []string{"lotr", "fotr", "tlotr"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("got %+v, want it to contain %+q", got, want)
+		}
+	}
+	if got := initials("Carmencita"); len(got) != 0 {
+		t.Errorf("got %+v, want no initials for a single word", got)
+	}
+}
+
+func TestRuntimeDistributions(t *testing.T) {
+	dir := newTmpDir(t)
+	defer dir.Cleanup()
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	index, err := OpenIndex(dir.dir)
+	if err != nil {
+		t.Fatalf("failed to open existing index %+v", err)
+	}
+	defer index.Close()
+
+	got, err := index.RuntimeDistributions()
+	if err != nil {
+		t.Fatalf("unable to load distributions: %+v", err)
+	}
+	want := map[string]*RuntimeDistribution{
+		"short":    {Count: 1, Mean: 1},
+		"tvSeries": {Count: 1, Mean: 30},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRuntimeStats(t *testing.T) {
+	stats := make(runtimeStats)
+	for _, minutes := range []int32{80, 90, 100, 0, maxRuntime + 1} {
+		stats.add("movie", minutes)
+	}
+	got := stats["movie"]
+	if got.Count != 3 || got.Mean != 90 {
+		t.Errorf("got %+v, want 3 runtimes with mean 90", got)
+	}
+	if want := math.Sqrt(200.0 / 3); math.Abs(got.StdDev-want) > 1e-9 {
+		t.Errorf("got stddev %f, want %f", got.StdDev, want)
+	}
+}
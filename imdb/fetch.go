@@ -1,11 +1,22 @@
 package imdb
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/cavaliergopher/grab/v3"
 	"github.com/dustin/go-humanize"
 )
 
@@ -13,10 +24,14 @@ const (
 	basics   = "title.basics.tsv.gz"
 	episodes = "title.episode.tsv.gz"
 	ratings  = "title.ratings.tsv.gz"
+	// manifestFile records what was downloaded, so that
+	// unchanged files can be skipped next time.
+	manifestFile = "datasets.json"
 )
 
 var (
-	// datasetSource is really a constant, but is a variable for testing to inject the fake.
+	// datasetSource is the default source for Fetch. It is really
+	// a constant, but is a variable for testing to inject the fake.
 	datasetSource = "https://datasets.imdbws.com/"
 	desiredFiles  = [...]string{
 		// Basic information about the content, including its unique identifier and title.
@@ -28,31 +43,258 @@ var (
 	}
 )
 
-// Fetch downloads all IMDb metadata that is needed for the index.
-func Fetch(ctx context.Context, dir string) error {
-	client := grab.NewClient()
-	// For some reason, it looks like AWS Cloudfront (which is the
-	// CDN for IMDb) does something weird if compression is
-	// enabled between the HEAD and GET requests. So, just disable
-	// it.
-	client.HTTPClient.(*http.Client).Transport.(*http.Transport).DisableCompression = true
-
-	requests := make([]*grab.Request, 0, len(desiredFiles))
-	for _, file := range desiredFiles {
-		r, err := grab.NewRequest(dir, datasetSource+file)
+// datasetInfo records the validators the server returned for a
+// single downloaded dataset file.
+type datasetInfo struct {
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	Size         int64
+	// Pending is set until the index is successfully rebuilt with
+	// the file, so that a failed rebuild is retried even if the
+	// file does not change again.
+	Pending bool `json:",omitempty"`
+}
+
+// manifest records every dataset file that has been downloaded.
+type manifest struct {
+	Files map[string]*datasetInfo
+}
+
+func loadManifest(dir string) (*manifest, error) {
+	result := &manifest{
+		Files: make(map[string]*datasetInfo),
+	}
+	b, err := os.ReadFile(path.Join(dir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, result); err != nil {
+		return nil, err
+	}
+	if result.Files == nil {
+		result.Files = make(map[string]*datasetInfo)
+	}
+	return result, nil
+}
+
+// save atomically replaces the manifest in dir.
+func (m *manifest) save(dir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path.Join(dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path.Join(dir, manifestFile))
+}
+
+// markIndexed records that the index was rebuilt with every dataset
+// file in dir.
+func markIndexed(dir string) error {
+	m, err := loadManifest(dir)
+	if err != nil {
+		return err
+	}
+	pending := false
+	for _, info := range m.Files {
+		pending = pending || info.Pending
+		info.Pending = false
+	}
+	if !pending {
+		return nil
+	}
+	return m.save(dir)
+}
+
+// verifyGzip reads the entirety of a gzip file, which checks its
+// CRC-32 and length trailers.
+func verifyGzip(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// fetchOne downloads a single dataset file into dir, unless the
+// server reports it is unchanged since prev was recorded. The new
+// file only replaces the old one once its integrity is verified. It
+// returns the new datasetInfo, or nil if the file was unchanged.
+func fetchOne(ctx context.Context, client *http.Client, source string, dir string, file string, prev *datasetInfo) (*datasetInfo, error) {
+	dst := path.Join(dir, file)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source+file, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Only make the request conditional if there is actually
+	// something on disk to fall back on.
+	if _, err := os.Stat(dst); err == nil && prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		fmt.Printf("%s is unchanged\n", req.URL)
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("unable to download %s: %s", req.URL, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, file+".*.download")
+	if err != nil {
+		return nil, err
+	}
+	// Clean up the temporary file, unless it gets renamed into place.
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	size, err := io.Copy(tmp, resp.Body)
+	if err != nil {
+		tmp.Close() //nolint:errcheck
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if resp.ContentLength >= 0 && size != resp.ContentLength {
+		return nil, fmt.Errorf("truncated download of %s: got %d bytes, want %d", req.URL, size, resp.ContentLength)
+	}
+	if err := verifyGzip(tmp.Name()); err != nil {
+		return nil, fmt.Errorf("corrupt download of %s: %w", req.URL, err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start).Seconds()
+	fmt.Printf("Downloaded %s to %s (%s/s)\n", req.URL, dst, humanize.Bytes(uint64(float64(size)/max(elapsed, 0.001))))
+	return &datasetInfo{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Size:         size,
+	}, nil
+}
+
+// normalizeSource turns a source into a URL ending in `/`, to which
+// the dataset filenames can be appended. Sources without a scheme are
+// assumed to be local directories.
+func normalizeSource(source string) (string, error) {
+	if source == "" {
+		return datasetSource, nil
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http", "https", "file":
+	case "":
+		abs, err := filepath.Abs(source)
 		if err != nil {
-			return err
+			return "", err
 		}
-		r.WithContext(ctx)
-		requests = append(requests, r)
+		u = &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	default:
+		return "", fmt.Errorf("unsupported dataset source %+q", source)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
 	}
+	return u.String(), nil
+}
 
-	respch := client.DoBatch(4, requests...)
-	for resp := range respch {
-		if err := resp.Err(); err != nil {
-			return err
+// Fetch downloads all IMDb metadata that is needed for the index,
+// skipping files that have not changed since they were last
+// downloaded. It returns the names of the files that changed since
+// the index was last rebuilt with them, which includes files that
+// were downloaded before, if the rebuild failed.
+//
+// source is where to fetch the datasets from. If empty, the official
+// IMDb source is used. Otherwise, it can be the URL of a mirror,
+// either http(s):// or file://, or a path to a local directory
+// containing the datasets (e.g., for machines without internet
+// access). Local files are subject to the same validation as
+// downloaded ones.
+func Fetch(ctx context.Context, source string, dir string) ([]string, error) {
+	source, err := normalizeSource(source)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The datasets are already gzipped, and must be stored exactly
+	// as served, so that they can be verified and their size
+	// compared against Content-Length. Don't let the transport ask
+	// for, and transparently undo, another layer of compression.
+	transport.DisableCompression = true
+	// file:// is served like a regular HTTP server would, which
+	// means conditional requests work for local files, too.
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	client := &http.Client{Transport: transport}
+
+	type result struct {
+		file string
+		info *datasetInfo
+		err  error
+	}
+	results := make([]result, len(desiredFiles))
+	wg := sync.WaitGroup{}
+	for i, file := range desiredFiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := fetchOne(ctx, client, source, dir, file, m.Files[file])
+			results[i] = result{file: file, info: info, err: err}
+		}()
+	}
+	wg.Wait()
+
+	changed := make([]string, 0)
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		if r.info != nil {
+			r.info.Pending = true
+			m.Files[r.file] = r.info
 		}
-		fmt.Printf("Downloaded %s to %s (%s/s)\n", resp.Request.URL(), resp.Filename, humanize.Bytes(uint64(resp.BytesPerSecond())))
+		if info := m.Files[r.file]; info != nil && info.Pending {
+			changed = append(changed, r.file)
+		}
+	}
+	// Even if some files failed, record the ones that succeeded
+	// so they don't need to be downloaded again.
+	if err := m.save(dir); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
-	return nil
+	return changed, nil
 }
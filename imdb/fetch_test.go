@@ -4,7 +4,13 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestFetchSucceeds(t *testing.T) {
@@ -34,7 +40,209 @@ func TestFetchSucceeds(t *testing.T) {
 
 	dst := newTmpDir(t)
 	defer dst.Cleanup()
-	if err := Fetch(t.Context(), dst.dir); err != nil {
+	if _, err := Fetch(t.Context(), "", dst.dir); err != nil {
 		t.Fatal(err)
 	}
 }
+
+// newDatasetServer serves the testdata from a fresh directory, which
+// is returned so that tests can modify it.
+func newDatasetServer(t *testing.T) (*httptest.Server, string) {
+	src := t.TempDir()
+	if err := copyTestData(src); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(src)))
+	t.Cleanup(server.Close)
+	datasetSource = server.URL + "/"
+	return server, src
+}
+
+func TestFetchSkipsUnchanged(t *testing.T) {
+	_, src := newDatasetServer(t)
+	dst := t.TempDir()
+
+	changed, err := Fetch(t.Context(), "", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(changed)
+	want := slices.Sorted(slices.Values(desiredFiles[:]))
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("got %+v changed, want %+v", changed, want)
+	}
+
+	// As if the index was rebuilt.
+	if err := markIndexed(dst); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = Fetch(t.Context(), "", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("got %+v changed, want nothing", changed)
+	}
+
+	// Touch one of the files on the server so it looks new.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path.Join(src, ratings), future, future); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = Fetch(t.Context(), "", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{ratings}) {
+		t.Errorf("got %+v changed, want [%s]", changed, ratings)
+	}
+}
+
+func TestFetchRetriesFailedRebuild(t *testing.T) {
+	newDatasetServer(t)
+	dst := t.TempDir()
+	if _, err := Fetch(t.Context(), "", dst); err != nil {
+		t.Fatal(err)
+	}
+	good, err := os.ReadFile(path.Join(dst, ratings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt a dataset so the rebuild fails.
+	if err := os.WriteFile(path.Join(dst, ratings), []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rebuild(dst); err == nil {
+		t.Fatal("rebuild unexpectedly succeeded with corrupt data")
+	}
+	if err := os.WriteFile(path.Join(dst, ratings), good, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed on the server, but the index still needs to
+	// be rebuilt.
+	changed, err := Fetch(t.Context(), "", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(changed)
+	want := slices.Sorted(slices.Values(desiredFiles[:]))
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("got %+v changed, want %+v", changed, want)
+	}
+	if err := Rebuild(dst); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = Fetch(t.Context(), "", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("got %+v changed after rebuilding, want nothing", changed)
+	}
+}
+
+func TestFetchRejectsCorruptDownload(t *testing.T) {
+	_, src := newDatasetServer(t)
+	dst := t.TempDir()
+	if _, err := Fetch(t.Context(), "", dst); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(path.Join(dst, basics))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the file on the server with something that isn't gzip.
+	if err := os.WriteFile(path.Join(src, basics), []byte("definitely not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path.Join(src, basics), future, future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Fetch(t.Context(), "", dst); err == nil {
+		t.Fatal("fetch unexpectedly accepted a corrupt file")
+	}
+	got, err := os.ReadFile(path.Join(dst, basics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("corrupt download replaced the previous copy")
+	}
+}
+
+func TestFetchFromLocalDirectory(t *testing.T) {
+	src := t.TempDir()
+	if err := copyTestData(src); err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range map[string]string{
+		"path":     src,
+		"file url": "file://" + src,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dst := t.TempDir()
+			changed, err := Fetch(t.Context(), source, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changed) != len(desiredFiles) {
+				t.Errorf("got %+v changed, want all files", changed)
+			}
+			if err := markIndexed(dst); err != nil {
+				t.Fatal(err)
+			}
+			changed, err = Fetch(t.Context(), source, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changed) != 0 {
+				t.Errorf("got %+v changed, want nothing", changed)
+			}
+		})
+	}
+}
+
+func TestFetchFromLocalDirectoryRejectsCorruptFile(t *testing.T) {
+	src := t.TempDir()
+	if err := copyTestData(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(src, episodes), []byte("definitely not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if _, err := Fetch(t.Context(), src, dst); err == nil {
+		t.Fatal("fetch unexpectedly accepted a corrupt file")
+	}
+	if _, err := os.Stat(path.Join(dst, episodes)); err == nil {
+		t.Error("corrupt file was imported")
+	}
+}
+
+func TestNormalizeSource(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+		err      bool
+	}{
+		"default":       {input: "", expected: datasetSource},
+		"mirror":        {input: "https://mirror.example/imdb", expected: "https://mirror.example/imdb/"},
+		"file url":      {input: "file:///mnt/usb/imdb/", expected: "file:///mnt/usb/imdb/"},
+		"absolute path": {input: "/mnt/usb/imdb", expected: "file:///mnt/usb/imdb/"},
+		"bad scheme":    {input: "ftp://mirror.example/imdb", err: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := normalizeSource(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %+v, want error %v", err, tt.err)
+			}
+			if got != tt.expected {
+				t.Errorf("got %+q, want %+q", got, tt.expected)
+			}
+		})
+	}
+}
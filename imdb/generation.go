@@ -0,0 +1,280 @@
+package imdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Rather than building the index in place, Rebuild creates a new
+// generation of the index in its own directory, validates it, and
+// only then atomically points `current` at it. This means that a
+// crash (or bad data) mid-build never leaves a broken index behind,
+// and the data can be refreshed without deleting anything by hand.
+//
+// The on-disk layout is
+//
+//	dir/imdb.generations/
+//	  current            -- contains the name of the current generation
+//	  previous           -- contains the name of the previous generation
+//	  building/          -- the generation being built, if any
+//	  20250712T000000.../ -- a complete generation
+//
+// Only the current and previous generations are kept; the previous
+// generation can be restored with Rollback.
+
+const (
+	generationsDir     = "imdb.generations"
+	buildingGeneration = "building"
+	currentPointer     = "current"
+	previousPointer    = "previous"
+	generationFormat   = "20060102T150405.000000000Z"
+	levelDbDoneMarker  = "leveldb.done"
+	pointerTempSuffix  = ".tmp"
+)
+
+// resolveGeneration returns the directory containing the current
+// generation of the index. If there are no generations, dir itself
+// is returned, as it is assumed to contain an index created directly
+// by NewIndex.
+func resolveGeneration(dir string) (string, error) {
+	current, err := readPointer(path.Join(dir, generationsDir), currentPointer)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dir, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, generationsDir, current), nil
+}
+
+func readPointer(root string, name string) (string, error) {
+	b, err := os.ReadFile(path.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	generation := strings.TrimSpace(string(b))
+	if generation == "" || strings.ContainsAny(generation, "/\\") {
+		return "", fmt.Errorf("invalid generation %+q in %s", generation, name)
+	}
+	return generation, nil
+}
+
+// writePointer atomically replaces the named pointer by writing to a
+// temporary file and renaming it into place.
+func writePointer(root string, name string, generation string) error {
+	tmp := path.Join(root, name+pointerTempSuffix)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(generation + "\n"); err != nil {
+		f.Close() //nolint:errcheck
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close() //nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path.Join(root, name))
+}
+
+// canResume reports whether a previous, interrupted build already
+// completed the LevelDB phase using the datasets that are currently
+// in dir.
+func canResume(dir string, building string) bool {
+	marker, err := os.Stat(path.Join(building, levelDbDoneMarker))
+	if err != nil {
+		return false
+	}
+	for _, file := range desiredFiles {
+		dataset, err := os.Stat(path.Join(dir, file))
+		if err != nil || dataset.ModTime().After(marker.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// build populates the generation in building from the datasets in
+// dir, resuming an interrupted build if possible.
+func build(dir string, building string) error {
+	idx := &Index{
+		dir:      building,
+		datasets: dir,
+	}
+	defer idx.Close()
+	if canResume(dir, building) {
+		log.Println("Resuming interrupted build")
+		if err := os.RemoveAll(path.Join(building, imdbBleve)); err != nil {
+			return err
+		}
+		if err := idx.openLevelDb(levelDbResume); err != nil {
+			return err
+		}
+	} else {
+		if err := os.RemoveAll(building); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(building, 0755); err != nil {
+			return err
+		}
+		if err := idx.openLevelDb(levelDbCreate); err != nil {
+			return err
+		}
+		if err := idx.makeLevelDb(); err != nil {
+			return err
+		}
+		marker, err := os.Create(path.Join(building, levelDbDoneMarker))
+		if err != nil {
+			return err
+		}
+		if err := marker.Close(); err != nil {
+			return err
+		}
+	}
+	if err := idx.newBleve(); err != nil {
+		return err
+	}
+	if err := idx.makeSearch(); err != nil {
+		return err
+	}
+	return idx.writeSnapshot()
+}
+
+// validate checks that the index in dir can be opened and queried,
+// and that search results can be resolved to titles.
+func validate(dir string) error {
+	idx, err := OpenIndex(dir)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	count, err := idx.index.DocCount()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("search index in %s is empty", dir)
+	}
+	request := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	request.Size = 1
+	result, err := idx.index.Search(request)
+	if err != nil {
+		return err
+	}
+	if len(result.Hits) == 0 {
+		return fmt.Errorf("search index in %s returned no results", dir)
+	}
+	if _, err := idx.findTitle(result.Hits[0].ID); err != nil {
+		return fmt.Errorf("search index in %s is inconsistent with titles: %w", dir, err)
+	}
+	return nil
+}
+
+// prune removes every generation other than the current and previous
+// ones, including any abandoned builds.
+func prune(root string, keep ...string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if name == buildingGeneration || slices.Contains(keep, name) {
+			continue
+		}
+		log.Printf("Removing old index generation %s\n", name)
+		if err := os.RemoveAll(path.Join(root, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasIndex reports whether dir has a current generation of the index.
+func HasIndex(dir string) bool {
+	_, err := readPointer(path.Join(dir, generationsDir), currentPointer)
+	return err == nil
+}
+
+// Rebuild builds a new generation of the index from the datasets in
+// dir (as downloaded by Fetch), validates it, and makes it the
+// current generation. The previously-current generation is kept so
+// that it can be restored with Rollback. Once the new generation is
+// current, Fetch no longer reports the datasets it was built from as
+// changed.
+func Rebuild(dir string) error {
+	root := path.Join(dir, generationsDir)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	building := path.Join(root, buildingGeneration)
+	if err := build(dir, building); err != nil {
+		return err
+	}
+	log.Println("Validating index")
+	if err := validate(building); err != nil {
+		return err
+	}
+	generation := time.Now().UTC().Format(generationFormat)
+	if err := os.Rename(building, path.Join(root, generation)); err != nil {
+		return err
+	}
+	if err := os.Remove(path.Join(root, generation, levelDbDoneMarker)); err != nil {
+		return err
+	}
+	previous, err := readPointer(root, currentPointer)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if previous != "" {
+		if err := writePointer(root, previousPointer, previous); err != nil {
+			return err
+		}
+	}
+	if err := writePointer(root, currentPointer, generation); err != nil {
+		return err
+	}
+	log.Printf("Index generation %s is now current\n", generation)
+	if err := markIndexed(dir); err != nil {
+		return err
+	}
+	return prune(root, generation, previous)
+}
+
+// Rollback makes the previous generation of the index current again,
+// and the current generation the previous one.
+func Rollback(dir string) error {
+	root := path.Join(dir, generationsDir)
+	current, err := readPointer(root, currentPointer)
+	if err != nil {
+		return err
+	}
+	previous, err := readPointer(root, previousPointer)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no previous index generation to roll back to")
+	}
+	if err != nil {
+		return err
+	}
+	if err := writePointer(root, currentPointer, previous); err != nil {
+		return err
+	}
+	log.Printf("Index generation %s is now current\n", previous)
+	return writePointer(root, previousPointer, current)
+}
@@ -0,0 +1,142 @@
+package imdb
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRebuildAndRollback(t *testing.T) {
+	dir := newTmpDir(t)
+	defer dir.Cleanup()
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	root := path.Join(dir.dir, generationsDir)
+
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	first, err := readPointer(root, currentPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to rebuild index: %+v", err)
+	}
+	second, err := readPointer(root, currentPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("rebuild did not produce a new generation")
+	}
+	previous, err := readPointer(root, previousPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != first {
+		t.Errorf("got previous generation %s, want %s", previous, first)
+	}
+
+	// The current generation must be usable via OpenIndex.
+	index, err := OpenIndex(dir.dir)
+	if err != nil {
+		t.Fatalf("failed to open current generation: %+v", err)
+	}
+	index.Close()
+
+	if err := Rollback(dir.dir); err != nil {
+		t.Fatal(err)
+	}
+	current, err := readPointer(root, currentPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != first {
+		t.Errorf("got current generation %s after rollback, want %s", current, first)
+	}
+
+	// A third build should prune the oldest generation.
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to rebuild index: %+v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generations := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			generations++
+		}
+	}
+	if generations != 2 {
+		t.Errorf("got %d generations, want 2", generations)
+	}
+}
+
+func TestFailedRebuildKeepsCurrent(t *testing.T) {
+	dir := newTmpDir(t)
+	defer dir.Cleanup()
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	root := path.Join(dir.dir, generationsDir)
+	want, err := readPointer(root, currentPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt a dataset so the next build fails part-way.
+	if err := os.WriteFile(path.Join(dir.dir, ratings), []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rebuild(dir.dir); err == nil {
+		t.Fatal("rebuild unexpectedly succeeded with corrupt data")
+	}
+	got, err := readPointer(root, currentPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got current generation %s, want %s", got, want)
+	}
+	index, err := OpenIndex(dir.dir)
+	if err != nil {
+		t.Fatalf("failed to open current generation: %+v", err)
+	}
+	index.Close()
+
+	// Titles were already loaded, so the interrupted build can be
+	// resumed, but only until the datasets change.
+	building := path.Join(root, buildingGeneration)
+	if !canResume(dir.dir, building) {
+		t.Error("unable to resume interrupted build")
+	}
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatal(err)
+	}
+	if canResume(dir.dir, building) {
+		t.Error("resumed a build even though the datasets changed")
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to rebuild index: %+v", err)
+	}
+}
+
+func TestRollbackWithoutPrevious(t *testing.T) {
+	dir := newTmpDir(t)
+	defer dir.Cleanup()
+	if err := copyTestData(dir.dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	if err := Rebuild(dir.dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	if err := Rollback(dir.dir); err == nil {
+		t.Error("rollback unexpectedly succeeded without a previous generation")
+	}
+}
@@ -0,0 +1,274 @@
+package imdb
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	unicodetokenizer "github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+const (
+	// titleWordsAnalyzer splits a title into lowercase words,
+	// without stemming or removing stop words.
+	titleWordsAnalyzer = "title_words"
+	// titlePrefixAnalyzer additionally indexes every prefix of
+	// every word, so that truncated words still match.
+	titlePrefixAnalyzer = "title_prefix"
+	titleEdgeNgram      = "title_edge_ngram"
+	// maxInitials is the longest run of words whose initials are
+	// indexed.
+	maxInitials = 6
+)
+
+// searchDocument is what is indexed for full-text search for every
+// title. Besides the title itself, the fields needed to narrow down a
+// search are indexed with their types, so that they can be filtered
+// on by the search engine rather than by the caller.
+type searchDocument struct {
+	Title string
+	// TitlePrefix is the title again, analyzed with
+	// titlePrefixAnalyzer.
+	TitlePrefix string
+	// Initials are the initials of every run of words in the
+	// title, e.g., "lotr" for "The Lord of the Rings".
+	Initials       []string
+	TitleType      string
+	StartYear      int32
+	RuntimeMinutes int32
+	Genres         []string
+	IsAdult        bool
+	AverageRating  float32
+	NumVotes       int
+}
+
+func newSearchDocument(title *pb.Title) *searchDocument {
+	return &searchDocument{
+		Title:          title.GetPrimaryTitle(),
+		TitlePrefix:    title.GetPrimaryTitle(),
+		Initials:       initials(title.GetPrimaryTitle()),
+		TitleType:      title.GetTitleType(),
+		StartYear:      title.GetStartYear(),
+		RuntimeMinutes: title.GetRuntimeMinutes(),
+		Genres:         title.GetGenres(),
+		IsAdult:        title.GetIsAdult(),
+	}
+}
+
+// Words splits a title (or disc name) into lowercase words,
+// discarding punctuation.
+func Words(title string) []string {
+	return strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// initials returns the initials of every run of 2 to maxInitials
+// consecutive words in the title.
+func initials(title string) []string {
+	words := Words(title)
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for start := range words {
+		acronym := []rune{}
+		for _, word := range words[start:min(len(words), start+maxInitials)] {
+			acronym = append(acronym, []rune(word)[0])
+			if len(acronym) < 2 || seen[string(acronym)] {
+				continue
+			}
+			seen[string(acronym)] = true
+			result = append(result, string(acronym))
+		}
+	}
+	return result
+}
+
+func addTitleAnalyzers(m *mapping.IndexMappingImpl) error {
+	if err := m.AddCustomTokenFilter(titleEdgeNgram, map[string]any{
+		"type": edgengram.Name,
+		"back": false,
+		"min":  2.0,
+		"max":  20.0,
+	}); err != nil {
+		return err
+	}
+	if err := m.AddCustomAnalyzer(titleWordsAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     unicodetokenizer.Name,
+		"token_filters": []any{lowercase.Name},
+	}); err != nil {
+		return err
+	}
+	return m.AddCustomAnalyzer(titlePrefixAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     unicodetokenizer.Name,
+		"token_filters": []any{lowercase.Name, titleEdgeNgram},
+	})
+}
+
+func newSearchDocumentMapping() *mapping.DocumentMapping {
+	doc := bleve.NewDocumentMapping()
+	title := bleve.NewTextFieldMapping()
+	title.Analyzer = "en"
+	doc.AddFieldMappingsAt("Title", title)
+	prefix := bleve.NewTextFieldMapping()
+	prefix.Analyzer = titlePrefixAnalyzer
+	prefix.Store = false
+	prefix.IncludeInAll = false
+	doc.AddFieldMappingsAt("TitlePrefix", prefix)
+	// Keywords are matched exactly, rather than being analyzed as
+	// English text.
+	for _, field := range []string{"TitleType", "Genres"} {
+		doc.AddFieldMappingsAt(field, bleve.NewKeywordFieldMapping())
+	}
+	initials := bleve.NewKeywordFieldMapping()
+	initials.Store = false
+	initials.IncludeInAll = false
+	doc.AddFieldMappingsAt("Initials", initials)
+	for _, field := range []string{"StartYear", "RuntimeMinutes", "AverageRating", "NumVotes"} {
+		doc.AddFieldMappingsAt(field, bleve.NewNumericFieldMapping())
+	}
+	doc.AddFieldMappingsAt("IsAdult", bleve.NewBooleanFieldMapping())
+	return doc
+}
+
+// MatchMode is how the text of a TitleQuery is matched against
+// titles, from strictest to most lenient.
+type MatchMode int
+
+const (
+	// MatchExact matches the (stemmed) words of the title.
+	MatchExact MatchMode = iota
+	// MatchFuzzy additionally matches words that are misspelled
+	// by a single edit.
+	MatchFuzzy
+	// MatchPrefix matches words that are prefixes of words in the
+	// title (e.g., truncated volume names), or the initials of
+	// runs of words in the title (e.g., abbreviated volume
+	// names).
+	MatchPrefix
+)
+
+var matchModeNames = map[MatchMode]string{
+	MatchExact:  "exact",
+	MatchFuzzy:  "fuzzy",
+	MatchPrefix: "prefix",
+}
+
+func (m MatchMode) String() string {
+	if name, ok := matchModeNames[m]; ok {
+		return name
+	}
+	return "MatchMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// TitleQuery is a structured search for titles. Text is required;
+// every other field is optional, and narrows down the results if
+// set.
+type TitleQuery struct {
+	// Text is matched against the title.
+	Text string
+	// Match is how strictly Text must match.
+	Match MatchMode
+	// TitleTypes restricts the results to any of the given types
+	// (e.g., "movie", "tvSeries").
+	TitleTypes []string
+	// MinRuntime and MaxRuntime restrict the results to titles
+	// whose runtime in minutes is in the inclusive range. Titles
+	// without a known runtime never match a range.
+	MinRuntime int32
+	MaxRuntime int32
+	// Year, if set, ranks titles that started within a year of it
+	// higher. Since the year printed on a disc is not necessarily
+	// the year a title was first released (e.g., for a later
+	// season of a series), it does not exclude any titles.
+	Year int32
+	// Size is the maximum number of results. If 0, at most 100
+	// results are returned, as with Search.
+	Size int
+}
+
+func numericRange(field string, lo, hi int32) query.Query {
+	var min, max *float64
+	if lo > 0 {
+		v := float64(lo)
+		min = &v
+	}
+	if hi > 0 {
+		v := float64(hi)
+		max = &v
+	}
+	inclusive := true
+	q := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusive, &inclusive)
+	q.SetField(field)
+	return q
+}
+
+func (q *TitleQuery) buildText() query.Query {
+	switch q.Match {
+	case MatchFuzzy:
+		text := bleve.NewMatchQuery(q.Text)
+		text.SetField("Title")
+		text.SetFuzziness(1)
+		return text
+	case MatchPrefix:
+		// The words in the query are not split into prefixes
+		// themselves, otherwise every title sharing the first
+		// two letters of any word would match.
+		prefix := bleve.NewMatchQuery(q.Text)
+		prefix.SetField("TitlePrefix")
+		prefix.Analyzer = titleWordsAnalyzer
+		abbreviations := make([]query.Query, 0)
+		for _, word := range Words(q.Text) {
+			term := bleve.NewTermQuery(word)
+			term.SetField("Initials")
+			abbreviations = append(abbreviations, term)
+		}
+		return bleve.NewDisjunctionQuery(append(abbreviations, prefix)...)
+	}
+	text := bleve.NewMatchQuery(q.Text)
+	text.SetField("Title")
+	return text
+}
+
+// build translates the TitleQuery into a single search engine query.
+func (q *TitleQuery) build() query.Query {
+	must := []query.Query{q.buildText()}
+	if len(q.TitleTypes) > 0 {
+		types := make([]query.Query, 0, len(q.TitleTypes))
+		for _, titleType := range q.TitleTypes {
+			term := bleve.NewTermQuery(titleType)
+			term.SetField("TitleType")
+			types = append(types, term)
+		}
+		must = append(must, bleve.NewDisjunctionQuery(types...))
+	}
+	if q.MinRuntime > 0 || q.MaxRuntime > 0 {
+		must = append(must, numericRange("RuntimeMinutes", q.MinRuntime, q.MaxRuntime))
+	}
+	var should []query.Query
+	if q.Year > 0 {
+		should = append(should, numericRange("StartYear", q.Year-1, q.Year+1))
+	}
+	return query.NewBooleanQuery(must, should, nil)
+}
+
+// Query performs a structured search, streaming the matching titles
+// in order of descending score and popularity.
+func (i *Index) Query(ctx context.Context, q *TitleQuery) (<-chan *pb.Result, error) {
+	searchRequest := bleve.NewSearchRequest(q.build())
+	searchRequest.Size = 100
+	if q.Size > 0 {
+		searchRequest.Size = q.Size
+	}
+	return i.search(ctx, searchRequest)
+}
@@ -0,0 +1,82 @@
+package imdb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+)
+
+const (
+	runtimesFile = "runtimes.json"
+	// maxRuntime is the longest runtime, in minutes, that is
+	// considered when computing distributions. A handful of
+	// experimental titles run for days, and would otherwise skew
+	// the distributions of otherwise ordinary content.
+	maxRuntime = 600
+)
+
+// RuntimeDistribution describes the runtimes of all titles of a
+// single type.
+type RuntimeDistribution struct {
+	Count  int
+	Mean   float64
+	StdDev float64
+
+	// m2 is the sum of squared differences from the mean, for
+	// computing the variance incrementally.
+	m2 float64
+}
+
+// add accounts for a single runtime, using Welford's algorithm.
+func (d *RuntimeDistribution) add(minutes float64) {
+	d.Count++
+	delta := minutes - d.Mean
+	d.Mean += delta / float64(d.Count)
+	d.m2 += delta * (minutes - d.Mean)
+	d.StdDev = math.Sqrt(d.m2 / float64(d.Count))
+}
+
+// runtimeStats are the runtime distributions by title type.
+type runtimeStats map[string]*RuntimeDistribution
+
+func (s runtimeStats) add(titleType string, minutes int32) {
+	if minutes <= 0 || minutes > maxRuntime {
+		return
+	}
+	d, ok := s[titleType]
+	if !ok {
+		d = &RuntimeDistribution{}
+		s[titleType] = d
+	}
+	d.add(float64(minutes))
+}
+
+func (i *Index) writeRuntimes(stats runtimeStats) error {
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(i.dir, runtimesFile), b, 0644)
+}
+
+// RuntimeDistributions returns the distribution of runtimes for every
+// title type, as computed when the index was built. Indexes built
+// before distributions were computed have none, in which case
+// RuntimeDistributions returns nil without an error.
+func (i *Index) RuntimeDistributions() (map[string]*RuntimeDistribution, error) {
+	b, err := os.ReadFile(path.Join(i.dir, runtimesFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*RuntimeDistribution)
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package imdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	// mappingVersion must be incremented whenever the way the
+	// index is built changes (e.g., the search mapping, or what is
+	// stored in LevelDB), since the same datasets would then
+	// produce different identification results.
+	mappingVersion = 4
+)
+
+// DatasetProvenance describes a single dataset file an index was
+// built from.
+type DatasetProvenance struct {
+	SHA256       string
+	LastModified string `json:",omitempty"`
+	Size         int64
+}
+
+// Snapshot records exactly what an index was built from, so that
+// identifications made against it can be reproduced later.
+type Snapshot struct {
+	// ID uniquely identifies the combination of datasets and
+	// mapping version. Two indexes with the same ID produce the
+	// same results.
+	ID             string
+	BuildTime      time.Time
+	AutoripVersion string
+	MappingVersion int
+	Datasets       map[string]*DatasetProvenance
+}
+
+// autoripVersion returns the version of autorip from the build
+// information embedded by the go toolchain.
+func autoripVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			version += "+" + setting.Value
+		}
+	}
+	return version
+}
+
+func hashFile(filename string) (string, int64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close() //nolint:errcheck
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// newSnapshot describes the datasets in dir.
+func newSnapshot(dir string) (*Snapshot, error) {
+	m, err := loadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := &Snapshot{
+		BuildTime:      time.Now().UTC(),
+		AutoripVersion: autoripVersion(),
+		MappingVersion: mappingVersion,
+		Datasets:       make(map[string]*DatasetProvenance),
+	}
+	id := sha256.New()
+	for _, file := range slices.Sorted(slices.Values(desiredFiles[:])) {
+		filename := path.Join(dir, file)
+		hash, size, err := hashFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		dataset := &DatasetProvenance{
+			SHA256: hash,
+			Size:   size,
+		}
+		if info, ok := m.Files[file]; ok && info.LastModified != "" {
+			dataset.LastModified = info.LastModified
+		} else if stat, err := os.Stat(filename); err == nil {
+			dataset.LastModified = stat.ModTime().UTC().Format(http.TimeFormat)
+		}
+		result.Datasets[file] = dataset
+		fmt.Fprintf(id, "%s:%s\n", file, hash)
+	}
+	fmt.Fprintf(id, "mapping:%d\n", mappingVersion)
+	result.ID = hex.EncodeToString(id.Sum(nil))[:16]
+	return result, nil
+}
+
+// writeSnapshot records the provenance of the index being built.
+func (i *Index) writeSnapshot() error {
+	snapshot, err := newSnapshot(i.datasets)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(i.dir, snapshotFile), b, 0644)
+}
+
+// Snapshot returns the provenance of the index. Indexes built before
+// provenance was recorded have none, in which case Snapshot returns
+// nil without an error.
+func (i *Index) Snapshot() (*Snapshot, error) {
+	b, err := os.ReadFile(path.Join(i.dir, snapshotFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &Snapshot{}
+	if err := json.Unmarshal(b, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package imdb
+
+import (
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	if err := copyTestData(dir); err != nil {
+		t.Fatalf("unable to prepare testdata: %+v", err)
+	}
+	if err := Rebuild(dir); err != nil {
+		t.Fatalf("unable to build index: %+v", err)
+	}
+	index, err := OpenIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	snapshot, err := index.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot == nil {
+		t.Fatal("index has no snapshot")
+	}
+	if snapshot.MappingVersion != mappingVersion {
+		t.Errorf("got mapping version %d, want %d", snapshot.MappingVersion, mappingVersion)
+	}
+	if len(snapshot.Datasets) != len(desiredFiles) {
+		t.Errorf("got %d datasets, want %d", len(snapshot.Datasets), len(desiredFiles))
+	}
+	for file, dataset := range snapshot.Datasets {
+		if len(dataset.SHA256) != 64 || dataset.LastModified == "" {
+			t.Errorf("incomplete provenance for %s: %+v", file, dataset)
+		}
+	}
+
+	// The same datasets must always produce the same ID.
+	again, err := newSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != snapshot.ID {
+		t.Errorf("got ID %s for the same datasets, want %s", again.ID, snapshot.ID)
+	}
+}
+
+func TestSnapshotMissing(t *testing.T) {
+	index := &Index{dir: t.TempDir()}
+	snapshot, err := index.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot != nil {
+		t.Errorf("got %+v, want no snapshot", snapshot)
+	}
+}
@@ -0,0 +1,80 @@
+package makemkv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/achernya/autorip/bdmv"
+	"github.com/achernya/autorip/discid"
+)
+
+// minLength is the shortest playlist makemkvcon reports as a title.
+// It is passed to makemkvcon explicitly, and applied to discs that
+// are analyzed natively, so that both see the same titles.
+const minLength = 120 * time.Second
+
+// DiscInfoFromBDMV describes a parsed BDMV directory the same way
+// makemkvcon's `info` would, so that it can be identified without
+// makemkvcon. Only the attributes that can be read from the
+// playlists and clips are filled in; in particular, sizes are those
+// of the clips rather than makemkvcon's estimate of the output.
+func DiscInfoFromBDMV(disc *bdmv.Disc) *DiscInfo {
+	name := disc.Name
+	if name == "" {
+		name = disc.VolumeName
+	}
+	result := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Type:       "Blu-ray disc",
+			Name:       name,
+			VolumeName: disc.VolumeName,
+		},
+	}
+	for index, playlist := range disc.Titles(minLength) {
+		size := disc.Size(playlist)
+		title := TitleInfo{
+			GenericInfo: GenericInfo{
+				Name:           name,
+				Duration:       discid.FormatDuration(playlist.Duration()),
+				DiskSize:       fmt.Sprintf("%.1f GB", float64(size)/(1<<30)),
+				DiskSizeBytes:  strconv.FormatInt(size, 10),
+				SourceFileName: playlist.Name,
+				SegmentsCount:  strconv.Itoa(len(playlist.PlayItems)),
+				SegmentsMap:    bdmv.Segments(playlist),
+				OutputFileName: fmt.Sprintf("title_t%02d.mkv", index),
+			},
+		}
+		if chapters := playlist.Chapters(); chapters > 0 {
+			title.ChapterCount = strconv.Itoa(chapters)
+		}
+		if angles := playlist.Angles(); angles > 1 {
+			title.AngleInfo = strconv.Itoa(angles)
+		}
+		for _, stream := range playlist.Streams {
+			title.Streams = append(title.Streams, streamInfoFromBDMV(stream))
+		}
+		result.Titles = append(result.Titles, title)
+	}
+	return result
+}
+
+func streamInfoFromBDMV(stream bdmv.Stream) StreamInfo {
+	result := StreamInfo{
+		GenericInfo: GenericInfo{
+			Type:           stream.Type,
+			LangCode:       stream.LangCode,
+			CodecId:        stream.CodecID,
+			CodecShort:     stream.CodecShort,
+			VideoSize:      stream.VideoSize,
+			VideoFrameRate: stream.FrameRate,
+		},
+	}
+	if stream.Channels > 0 {
+		result.AudioChannelsCount = strconv.Itoa(stream.Channels)
+	}
+	if stream.SampleRate > 0 {
+		result.AudioSampleRate = strconv.Itoa(stream.SampleRate)
+	}
+	return result
+}
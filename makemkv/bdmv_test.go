@@ -0,0 +1,55 @@
+package makemkv
+
+import (
+	"path"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+)
+
+func TestAnalyzeBDMV(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// makemkvcon must not be needed to analyze a BDMV directory.
+	mkv := New(d, "/nonexistent/makemkvcon", ".")
+	root := path.Join("testdata", "FILM")
+	analysis, err := mkv.AnalyzeBDMV(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.New || analysis.DriveIndex != -1 {
+		t.Errorf("got %+v, want a new disc without a drive", analysis)
+	}
+	di := analysis.DiscInfo
+	if di.Name != "Film" || di.VolumeName != "FILM" {
+		t.Errorf("got %+q (%+q), want Film (FILM)", di.Name, di.VolumeName)
+	}
+	// The menu loop is too short, and 00801.mpls is a duplicate of
+	// 00800.mpls.
+	if len(di.Titles) != 2 {
+		t.Fatalf("got %d titles, want 2", len(di.Titles))
+	}
+	feature := di.Titles[1]
+	if feature.SourceFileName != "00800.mpls" || feature.Duration != "1:30:00" || feature.ChapterCount != "2" || feature.SegmentsMap != "1,2" {
+		t.Errorf("got feature %+v, want 00800.mpls, 1:30:00 with 2 chapters", feature.GenericInfo)
+	}
+	if len(feature.Streams) != 3 || feature.Streams[1].CodecShort != "DTS-HD MA" || feature.Streams[1].LangCode != "eng" {
+		t.Errorf("got streams %+v, want video, English DTS-HD MA and subtitles", feature.Streams)
+	}
+
+	// The main feature is the only title with chapters.
+	i := &Identifier{}
+	if filtered := i.FilterDiscInfo(di); len(filtered) != 1 || filtered[1] == nil {
+		t.Errorf("got filtered titles %v, want only title 1", filtered)
+	}
+
+	analysis, err = mkv.AnalyzeBDMV(path.Join(root, "BDMV"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.New {
+		t.Error("analysis incorrectly thinks same disc is new")
+	}
+}
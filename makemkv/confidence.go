@@ -0,0 +1,144 @@
+package makemkv
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/achernya/autorip/imdb"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+// The confidence in a candidate is the weighted mean of several
+// independent factors, each between 0 and 1. Factors that do not
+// apply to a disc (e.g., the year, if the disc name does not contain
+// one) are left out entirely, rather than counting as 0.
+
+const (
+	textWeight    = 0.35
+	votesWeight   = 0.15
+	runtimeWeight = 0.25
+	typeWeight    = 0.10
+	yearWeight    = 0.15
+	// wellKnownVotes is the number of votes at which a title is
+	// considered popular enough to be on disc.
+	wellKnownVotes = 100000
+)
+
+var (
+	// Lenient matches are less trustworthy than exact ones.
+	matchPenalties = map[imdb.MatchMode]float64{
+		imdb.MatchExact:  1,
+		imdb.MatchFuzzy:  0.9,
+		imdb.MatchPrefix: 0.8,
+	}
+)
+
+// Factor is a single component of a Candidate's confidence.
+type Factor struct {
+	Name   string
+	Value  float64
+	Weight float64
+	// Detail explains how the value was derived.
+	Detail string
+}
+
+// Candidate is a possible identity for a disc.
+type Candidate struct {
+	Title *pb.Title
+	// Match is how leniently the title had to be searched for.
+	Match      imdb.MatchMode
+	Confidence float64
+	Factors    []Factor
+}
+
+// Explanation returns a human-readable breakdown of the confidence.
+func (c *Candidate) Explanation() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "[%s] %s (%d, %s): confidence %.2f\n",
+		c.Title.GetTConst(), c.Title.GetPrimaryTitle(), c.Title.GetStartYear(), c.Title.GetTitleType(), c.Confidence)
+	for _, factor := range c.Factors {
+		fmt.Fprintf(&b, "  %-8s %.2f (weight %.2f): %s\n", factor.Name, factor.Value, factor.Weight, factor.Detail)
+	}
+	return b.String()
+}
+
+// candidateContext is everything known about the disc that a
+// candidate is compared against.
+type candidateContext struct {
+	name  string
+	year  int32
+	score *Score
+	// typeLikelihoods is how likely content of each IMDb title
+	// type is, as returned by plausibleTypes.
+	typeLikelihoods map[string]float64
+	maxScore        float64
+	match           imdb.MatchMode
+}
+
+func newCandidate(ctx *candidateContext, result *pb.Result) *Candidate {
+	title := result.GetEntry()
+	similarity := Similarity(ctx.name, title.GetPrimaryTitle())
+	searchScore := 0.0
+	if ctx.maxScore > 0 {
+		searchScore = result.GetScore() / ctx.maxScore
+	}
+	factors := []Factor{
+		{
+			Name:   "text",
+			Value:  (similarity + searchScore) / 2 * matchPenalties[ctx.match],
+			Weight: textWeight,
+			Detail: fmt.Sprintf("similarity %.2f, relative search score %.2f (%s match)", similarity, searchScore, ctx.match),
+		},
+		{
+			Name:   "votes",
+			Value:  min(1, math.Log10(float64(result.GetNumVotes())+1)/math.Log10(wellKnownVotes)),
+			Weight: votesWeight,
+			Detail: fmt.Sprintf("%d votes", result.GetNumVotes()),
+		},
+	}
+	runtime := time.Duration(title.GetRuntimeMinutes()) * time.Minute
+	factors = append(factors, Factor{
+		Name:   "runtime",
+		Value:  runtimeRatio(title.GetRuntimeMinutes(), ctx.score.Duration),
+		Weight: runtimeWeight,
+		Detail: fmt.Sprintf("%v listed, %v on disc", runtime, ctx.score.Duration),
+	})
+	// The likelihood is the ratio between the most and second most
+	// likely type, so 1 means the classifier could not tell. It is
+	// then scaled by how likely the type of this title is compared
+	// to the most likely one.
+	typeValue := 0.0
+	if ctx.score.Likelihood >= 1 {
+		typeValue = (1 - 1/ctx.score.Likelihood) * ctx.typeLikelihoods[title.GetTitleType()]
+	}
+	factors = append(factors, Factor{
+		Name:   "type",
+		Value:  typeValue,
+		Weight: typeWeight,
+		Detail: fmt.Sprintf("listed as %s, disc classified as %s (likelihood ratio %.2f)", title.GetTitleType(), ctx.score.Type, ctx.score.Likelihood),
+	})
+	if ctx.year > 0 {
+		diff := math.Abs(float64(title.GetStartYear() - ctx.year))
+		factors = append(factors, Factor{
+			Name:   "year",
+			Value:  max(0, 1-max(0, diff-1)/10),
+			Weight: yearWeight,
+			Detail: fmt.Sprintf("started %d, disc name says %d", title.GetStartYear(), ctx.year),
+		})
+	}
+	sum := 0.0
+	weights := 0.0
+	for _, factor := range factors {
+		sum += factor.Value * factor.Weight
+		weights += factor.Weight
+	}
+	return &Candidate{
+		Title:      title,
+		Match:      ctx.match,
+		Confidence: sum / weights,
+		Factors:    factors,
+	}
+}
@@ -0,0 +1,181 @@
+package makemkv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/achernya/autorip/imdb"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+func TestNewCandidate(t *testing.T) {
+	result := pb.Result_builder{
+		Score:    proto.Float64(2),
+		NumVotes: proto.Int32(wellKnownVotes),
+		Entry: pb.Title_builder{
+			PrimaryTitle:   proto.String("Blade Runner"),
+			TitleType:      proto.String("movie"),
+			StartYear:      proto.Int32(1982),
+			RuntimeMinutes: proto.Int32(117),
+		}.Build(),
+	}.Build()
+	ctx := &candidateContext{
+		name:     "BLADE RUNNER 1982",
+		year:     1982,
+		score:    &Score{Duration: 117 * time.Minute, Type: "movie", Likelihood: 4},
+		maxScore: 2,
+		match:    imdb.MatchExact,
+		typeLikelihoods: map[string]float64{
+			"movie": 1,
+			"video": 0.5,
+		},
+	}
+	got := newCandidate(ctx, result)
+	want := map[string]float64{
+		"text":    1,
+		"votes":   1,
+		"runtime": 1,
+		"type":    0.75,
+		"year":    1,
+	}
+	if len(got.Factors) != len(want) {
+		t.Fatalf("got %d factors, want %d", len(got.Factors), len(want))
+	}
+	sum := 0.0
+	for _, factor := range got.Factors {
+		// "1982" is not in the title, so the similarity is
+		// not perfect.
+		if factor.Name == "text" {
+			if factor.Value >= 1 || factor.Value < 0.75 {
+				t.Errorf("got text %f, want in [0.75, 1)", factor.Value)
+			}
+		} else if factor.Value != want[factor.Name] {
+			t.Errorf("got %s %f, want %f", factor.Name, factor.Value, want[factor.Name])
+		}
+		sum += factor.Weight
+	}
+	if got.Confidence <= 0.8 || got.Confidence > 1 {
+		t.Errorf("got confidence %f, want in (0.8, 1]", got.Confidence)
+	}
+
+	// Without a year in the name, that factor doesn't count at
+	// all, and a lenient match reduces confidence.
+	ctx.year = 0
+	ctx.match = imdb.MatchPrefix
+	lenient := newCandidate(ctx, result)
+	for _, factor := range lenient.Factors {
+		if factor.Name == "year" {
+			t.Errorf("got year factor %+v, want none", factor)
+		}
+	}
+	if lenient.Confidence >= got.Confidence {
+		t.Errorf("got confidence %f >= %f, want less for a prefix match", lenient.Confidence, got.Confidence)
+	}
+	if lenient.Explanation() == "" {
+		t.Error("got empty explanation")
+	}
+}
+
+func TestTypeFactorWhenClassifierUnsure(t *testing.T) {
+	titles := map[int]*TitleInfo{
+		0: {
+			GenericInfo: GenericInfo{
+				Duration: "1:40:00",
+			},
+		},
+	}
+	result := pb.Result_builder{
+		Entry: pb.Title_builder{
+			PrimaryTitle:   proto.String("Film"),
+			TitleType:      proto.String("movie"),
+			RuntimeMinutes: proto.Int32(100),
+		}.Build(),
+	}.Build()
+	typeFactor := func(dists map[string]*imdb.RuntimeDistribution) float64 {
+		scores, err := NewIdentifier(&fakeIndex{dists: dists}).DiscLikelyContains(titles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scores) != 1 || scores[0].Type != "movie" {
+			t.Fatalf("got %+v, want a movie", scores)
+		}
+		candidate := newCandidate(&candidateContext{
+			name:            "FILM",
+			score:           scores[0],
+			typeLikelihoods: plausibleTypes(scores[0]),
+		}, result)
+		for _, factor := range candidate.Factors {
+			if factor.Name == "type" {
+				return factor.Value
+			}
+		}
+		t.Fatal("got no type factor")
+		return 0
+	}
+	// Shorts are far less likely than either in both cases, but
+	// only the runner-up matters.
+	sure := typeFactor(map[string]*imdb.RuntimeDistribution{
+		"movie":     {Count: 100, Mean: 100, StdDev: 10},
+		"tvSpecial": {Count: 100, Mean: 60, StdDev: 10},
+		"short":     {Count: 100, Mean: 10, StdDev: 5},
+	})
+	unsure := typeFactor(map[string]*imdb.RuntimeDistribution{
+		"movie":     {Count: 100, Mean: 100, StdDev: 10},
+		"tvSpecial": {Count: 100, Mean: 95, StdDev: 10},
+		"short":     {Count: 100, Mean: 10, StdDev: 5},
+	})
+	if sure < 0.9 {
+		t.Errorf("got type factor %f when the classifier is sure, want at least 0.9", sure)
+	}
+	if unsure > 0.5 {
+		t.Errorf("got type factor %f when the classifier is unsure, want at most 0.5", unsure)
+	}
+}
+
+func TestMakePlanLowConfidence(t *testing.T) {
+	disc := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Name: "FILM",
+		},
+		Titles: []TitleInfo{
+			{
+				GenericInfo: GenericInfo{
+					Duration: "01:40:00",
+				},
+			},
+		},
+	}
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	for _, tt := range []struct {
+		minConfidence float64
+		want          bool
+	}{
+		{0, false},
+		{1, true},
+	} {
+		i := NewIdentifier(index)
+		i.MinConfidence = tt.minConfidence
+		plan, err := i.MakePlan(disc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.Identity == nil || len(plan.Candidates) != 1 {
+			t.Fatalf("got identity %+v with %d candidates, want 1", plan.Identity, len(plan.Candidates))
+		}
+		if plan.LowConfidence != tt.want {
+			t.Errorf("with MinConfidence %f got LowConfidence %t, want %t", tt.minConfidence, plan.LowConfidence, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package makemkv
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/achernya/autorip/discid"
+	"github.com/achernya/autorip/dvd"
+)
+
+// DiscInfoFromDVD describes a parsed VIDEO_TS directory the same way
+// makemkvcon's `info` would, so that it can be identified without
+// makemkvcon. Titles are named after the title set they are in,
+// which makemkvcon does not do, so a DVD analyzed natively has a
+// different fingerprint than the same DVD analyzed by makemkvcon.
+func DiscInfoFromDVD(disc *dvd.Disc) (*DiscInfo, error) {
+	titles, err := disc.Titles(minLength)
+	if err != nil {
+		return nil, err
+	}
+	result := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Type:       "DVD disc",
+			Name:       disc.VolumeName,
+			VolumeName: disc.VolumeName,
+		},
+	}
+	for index, t := range titles {
+		title := TitleInfo{
+			GenericInfo: GenericInfo{
+				Name:           disc.VolumeName,
+				Duration:       discid.FormatDuration(t.Duration),
+				DiskSize:       fmt.Sprintf("%.1f GB", float64(t.Size)/(1<<30)),
+				DiskSizeBytes:  strconv.FormatInt(t.Size, 10),
+				SourceFileName: t.Name,
+				SegmentsCount:  strconv.Itoa(len(t.ProgramChains)),
+				SegmentsMap:    t.Segments(),
+				OutputFileName: fmt.Sprintf("title_t%02d.mkv", index),
+			},
+		}
+		if t.Chapters > 0 {
+			title.ChapterCount = strconv.Itoa(t.Chapters)
+		}
+		if t.Angles > 1 {
+			title.AngleInfo = strconv.Itoa(t.Angles)
+		}
+		for _, stream := range t.Streams {
+			title.Streams = append(title.Streams, streamInfoFromDVD(stream))
+		}
+		result.Titles = append(result.Titles, title)
+	}
+	return result, nil
+}
+
+func streamInfoFromDVD(stream dvd.Stream) StreamInfo {
+	result := StreamInfo{
+		GenericInfo: GenericInfo{
+			Type:             stream.Type,
+			LangCode:         stream.LangCode,
+			CodecId:          stream.CodecID,
+			CodecShort:       stream.CodecShort,
+			VideoSize:        stream.VideoSize,
+			VideoAspectRatio: stream.AspectRatio,
+		},
+	}
+	if stream.Channels > 0 {
+		result.AudioChannelsCount = strconv.Itoa(stream.Channels)
+	}
+	if stream.SampleRate > 0 {
+		result.AudioSampleRate = strconv.Itoa(stream.SampleRate)
+	}
+	if flags := extensionFlags(stream); flags != 0 {
+		result.StreamFlags = strconv.Itoa(flags)
+	}
+	return result
+}
+
+// extensionFlags translates the code extension of a stream into the
+// flags makemkvcon would report for it.
+func extensionFlags(stream dvd.Stream) int {
+	switch stream.Type {
+	case dvd.Audio:
+		switch stream.Extension {
+		case dvd.ExtensionDirectorsComment:
+			return StreamDirectorsComments
+		case dvd.ExtensionAlternateComment:
+			return StreamAlternateDirectorsComments
+		case dvd.ExtensionVisuallyImpaired:
+			return StreamForVisuallyImpaired
+		}
+	case dvd.Subtitles:
+		switch stream.Extension {
+		case dvd.SubpictureExtensionForced:
+			return StreamForcedSubtitles
+		case dvd.SubpictureExtensionDirectorsComment, dvd.SubpictureExtensionLargeDirectorsComment,
+			dvd.SubpictureExtensionChildrensDirectorsComment:
+			return StreamDirectorsComments
+		}
+	}
+	return 0
+}
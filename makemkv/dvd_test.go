@@ -0,0 +1,78 @@
+package makemkv
+
+import (
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/dvd"
+)
+
+func TestAnalyzeDVD(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// makemkvcon must not be needed to analyze a VIDEO_TS directory.
+	mkv := New(d, "/nonexistent/makemkvcon", ".")
+	analysis, err := mkv.AnalyzeDVD(path.Join("testdata", "DVDFILM"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	di := analysis.DiscInfo
+	if di.VolumeName != "DVDFILM" {
+		t.Errorf("got volume %+q, want DVDFILM", di.VolumeName)
+	}
+	// The 1 minute logo is too short.
+	if len(di.Titles) != 2 {
+		t.Fatalf("got %d titles, want 2", len(di.Titles))
+	}
+	feature := di.Titles[0]
+	if feature.SourceFileName != "VTS_01_0.IFO:1" || feature.Duration != "1:30:00" || feature.ChapterCount != "3" || feature.AngleInfo != "2" {
+		t.Errorf("got feature %+v, want VTS_01_0.IFO:1, 1:30:00 with 3 chapters and 2 angles", feature.GenericInfo)
+	}
+	if len(feature.Streams) != 4 {
+		t.Fatalf("got %d streams, want 4", len(feature.Streams))
+	}
+	// The commentary can be dropped by the stream policy.
+	policy := StreamPolicy{DropCommentary: true}
+	decisions := policy.Select(&feature)
+	if !decisions[1].Keep || decisions[2].Keep {
+		t.Errorf("got decisions %v, want to keep the main audio and drop the commentary", decisions)
+	}
+
+	analysis, err = mkv.AnalyzeDVD(path.Join("testdata", "DVDFILM", "VIDEO_TS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.New {
+		t.Error("analysis incorrectly thinks same disc is new")
+	}
+}
+
+func TestStreamInfoFromDVD(t *testing.T) {
+	tests := []struct {
+		stream dvd.Stream
+		want   string
+	}{
+		{dvd.Stream{Type: dvd.Audio, Extension: dvd.ExtensionNormal}, ""},
+		{dvd.Stream{Type: dvd.Audio, Extension: dvd.ExtensionVisuallyImpaired}, strconv.Itoa(StreamForVisuallyImpaired)},
+		{dvd.Stream{Type: dvd.Audio, Extension: dvd.ExtensionDirectorsComment}, strconv.Itoa(StreamDirectorsComments)},
+		{dvd.Stream{Type: dvd.Audio, Extension: dvd.ExtensionAlternateComment}, strconv.Itoa(StreamAlternateDirectorsComments)},
+		// Subpictures use different codes than audio.
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionNormal}, ""},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionLarge}, ""},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionChildrens}, ""},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: 4}, ""},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionForced}, strconv.Itoa(StreamForcedSubtitles)},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionDirectorsComment}, strconv.Itoa(StreamDirectorsComments)},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionLargeDirectorsComment}, strconv.Itoa(StreamDirectorsComments)},
+		{dvd.Stream{Type: dvd.Subtitles, Extension: dvd.SubpictureExtensionChildrensDirectorsComment}, strconv.Itoa(StreamDirectorsComments)},
+	}
+	for _, tt := range tests {
+		if got := streamInfoFromDVD(tt.stream).StreamFlags; got != tt.want {
+			t.Errorf("%s with extension %d: got flags %+q, want %+q", tt.stream.Type, tt.stream.Extension, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package makemkv
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/achernya/autorip/db"
+	"gorm.io/datatypes"
+)
+
+// EditionTitle is a title that was ripped as the identity of a disc.
+// Together, the titles ripped are the edition of the identity that
+// is on the disc, e.g., the theatrical rather than the extended cut,
+// so that the same disc can be ripped the same way again, here or at
+// another site.
+type EditionTitle struct {
+	TitleIndex int
+	// Playlist is the source of the title, e.g., 00800.mpls. It
+	// is preferred over TitleIndex, which depends on what
+	// makemkvcon considers a title.
+	Playlist string `json:",omitempty"`
+}
+
+// marshalEdition returns the edition of the titles, as stored in
+// db.Identification.Edition, or nil if there are none.
+func marshalEdition(titles []*Score) (datatypes.JSON, error) {
+	if len(titles) == 0 {
+		return nil, nil
+	}
+	edition := make([]EditionTitle, 0, len(titles))
+	for _, title := range titles {
+		edition = append(edition, EditionTitle{TitleIndex: title.TitleIndex, Playlist: title.Playlist})
+	}
+	return json.Marshal(edition)
+}
+
+// parseEdition returns the edition recorded with an identification,
+// which may be empty.
+func parseEdition(edition datatypes.JSON) ([]EditionTitle, error) {
+	if len(edition) == 0 {
+		return nil, nil
+	}
+	result := []EditionTitle{}
+	if err := json.Unmarshal(edition, &result); err != nil {
+		return nil, fmt.Errorf("malformed edition: %w", err)
+	}
+	return result, nil
+}
+
+// chooseEdition returns the titles among likely that make up the
+// edition the disc was identified with before, in the same order, or
+// nil if any of them is missing.
+func chooseEdition(likely []*Score, identification *db.Identification) ([]*Score, error) {
+	edition, err := parseEdition(identification.Edition)
+	if err != nil || len(edition) == 0 {
+		return nil, err
+	}
+	result := make([]*Score, 0, len(edition))
+	for _, title := range edition {
+		var found *Score
+		for _, score := range likely {
+			if (title.Playlist != "" && score.Playlist == title.Playlist) ||
+				(title.Playlist == "" && score.TitleIndex == title.TitleIndex) {
+				found = score
+				break
+			}
+		}
+		if found == nil {
+			return nil, nil
+		}
+		result = append(result, found)
+	}
+	return result, nil
+}
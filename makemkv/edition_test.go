@@ -0,0 +1,101 @@
+package makemkv
+
+import (
+	"testing"
+
+	"github.com/achernya/autorip/db"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/datatypes"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+func TestChooseEdition(t *testing.T) {
+	likely := []*Score{
+		{TitleIndex: 0, Playlist: "00800.mpls"},
+		{TitleIndex: 1, Playlist: "00801.mpls"},
+	}
+	tests := map[string]struct {
+		edition string
+		want    []int
+	}{
+		"none":           {"", nil},
+		"by playlist":    {`[{"TitleIndex":5,"Playlist":"00801.mpls"}]`, []int{1}},
+		"by title index": {`[{"TitleIndex":1}]`, []int{1}},
+		"in order":       {`[{"TitleIndex":1,"Playlist":"00801.mpls"},{"TitleIndex":0,"Playlist":"00800.mpls"}]`, []int{1, 0}},
+		"missing title":  {`[{"TitleIndex":0,"Playlist":"00800.mpls"},{"TitleIndex":2,"Playlist":"00802.mpls"}]`, nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := chooseEdition(likely, &db.Identification{Edition: datatypes.JSON(tt.edition)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d titles, want %v", len(got), tt.want)
+			}
+			for i, score := range got {
+				if score.TitleIndex != tt.want[i] {
+					t.Errorf("got title %d at %d, want %d", score.TitleIndex, i, tt.want[i])
+				}
+			}
+		})
+	}
+	if _, err := chooseEdition(likely, &db.Identification{Edition: datatypes.JSON("{")}); err == nil {
+		t.Error("chooseEdition unexpectedly accepted a malformed edition")
+	}
+}
+
+func TestMakePlanForRipsKnownEdition(t *testing.T) {
+	title := func(duration string, playlist string) TitleInfo {
+		return TitleInfo{
+			GenericInfo: GenericInfo{
+				Duration:       duration,
+				SourceFileName: playlist,
+			},
+		}
+	}
+	// A theatrical and an extended cut.
+	disc := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Name: "FILM",
+		},
+		Titles: []TitleInfo{
+			title("01:50:00", "00800.mpls"),
+			title("02:10:00", "00801.mpls"),
+		},
+	}
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000001"),
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(130),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	i := NewIdentifier(index)
+	plan, err := i.MakePlan(disc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.RipTitles) != 1 || plan.RipTitles[0].Playlist != "00801.mpls" {
+		t.Fatalf("got %+v, want to rip the extended cut", plan.RipTitles)
+	}
+
+	// The disc was ripped as the theatrical cut before.
+	known := &db.Identification{
+		TConst:  "tt0000001",
+		Edition: datatypes.JSON(`[{"TitleIndex":9,"Playlist":"00800.mpls"}]`),
+	}
+	plan, err = i.MakePlanFor(&Analysis{DiscInfo: disc, Identification: known})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.RipTitles) != 1 || plan.RipTitles[0].Playlist != "00800.mpls" {
+		t.Errorf("got %+v, want to rip the theatrical cut as before", plan.RipTitles)
+	}
+}
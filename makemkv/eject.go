@@ -0,0 +1,27 @@
+package makemkv
+
+import (
+	"fmt"
+)
+
+// Ejector ejects the disc from a drive. It primarily exists to allow
+// faking in tests, since ejecting requires real hardware.
+type Ejector interface {
+	Eject(drive *Drive) error
+}
+
+// DeviceEjector ejects the disc by issuing an ioctl to the drive's
+// device node (Drive.DrivePath). It is only supported on Linux.
+type DeviceEjector struct{}
+
+var _ Ejector = DeviceEjector{}
+
+// Eject ejects the disc from the given drive, using the MakeMkv's
+// Ejector. The drive must have a DrivePath, which means it must have
+// come from ScanDrive.
+func (m *MakeMkv) Eject(drive *Drive) error {
+	if drive.DrivePath == "" {
+		return fmt.Errorf("drive %d has no device path, unable to eject", drive.Index)
+	}
+	return m.Ejector.Eject(drive)
+}
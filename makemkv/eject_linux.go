@@ -0,0 +1,23 @@
+package makemkv
+
+import (
+	"os"
+	"syscall"
+)
+
+// cdromEject is CDROMEJECT from <linux/cdrom.h>.
+const cdromEject = 0x5309
+
+func (DeviceEjector) Eject(drive *Drive) error {
+	// O_NONBLOCK is required to open a drive that is not ready
+	// (e.g., still spinning up).
+	f, err := os.OpenFile(drive.DrivePath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), cdromEject, 0); errno != 0 {
+		return &os.PathError{Op: "ioctl CDROMEJECT", Path: drive.DrivePath, Err: errno}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package makemkv
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func (DeviceEjector) Eject(drive *Drive) error {
+	return fmt.Errorf("ejecting %s is not supported on %s", drive.DrivePath, runtime.GOOS)
+}
@@ -0,0 +1,58 @@
+package makemkv
+
+import (
+	"path"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+)
+
+type fakeEjector struct {
+	ejected []string
+}
+
+func (f *fakeEjector) Eject(drive *Drive) error {
+	f.ejected = append(f.ejected, drive.DrivePath)
+	return nil
+}
+
+func TestEject(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	fake := &fakeEjector{}
+	mkv.Ejector = fake
+	drives, err := mkv.ScanDrive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mkv.Eject(drives[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.ejected) != 1 || fake.ejected[0] != "/dev/rdisk4" {
+		t.Errorf("got %+v, want [/dev/rdisk4] ejected", fake.ejected)
+	}
+}
+
+func TestEjectWithoutPath(t *testing.T) {
+	mkv := New(nil, "", ".")
+	mkv.Ejector = &fakeEjector{}
+	if err := mkv.Eject(&Drive{Index: 0}); err == nil {
+		t.Error("ejecting a drive without a device path unexpectedly succeeded")
+	}
+}
+
+func TestDriveStateString(t *testing.T) {
+	tests := map[DriveState]string{
+		DriveInserted:  "inserted",
+		DriveNoDrive:   "no drive",
+		DriveState(42): "DriveState(42)",
+	}
+	for state, want := range tests {
+		if got := state.String(); got != want {
+			t.Errorf("got %+q, want %+q", got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package makemkv
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ExtraType is the Type of a Score for a title that is not part of
+// the main content, such as a featurette, trailer or deleted scene.
+const ExtraType = "extra"
+
+// ExtrasLayout is how extras are named relative to the main content.
+type ExtrasLayout int
+
+const (
+	// ExtrasDiscard does not rip extras at all.
+	ExtrasDiscard ExtrasLayout = iota
+	// ExtrasFolder places extras in an Extras/ folder next to the
+	// main content.
+	ExtrasFolder
+	// ExtrasPlex places extras next to the main content, with
+	// Plex's -featurette suffix.
+	ExtrasPlex
+)
+
+var extrasLayoutNames = map[ExtrasLayout]string{
+	ExtrasDiscard: "discard",
+	ExtrasFolder:  "folder",
+	ExtrasPlex:    "plex",
+}
+
+func (l ExtrasLayout) String() string {
+	if name, ok := extrasLayoutNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("ExtrasLayout(%d)", int(l))
+}
+
+// ParseExtrasLayout returns the layout with the given name.
+func ParseExtrasLayout(name string) (ExtrasLayout, error) {
+	for layout, n := range extrasLayoutNames {
+		if n == name {
+			return layout, nil
+		}
+	}
+	return ExtrasDiscard, fmt.Errorf("unknown extras layout %+q", name)
+}
+
+// ExtrasPolicy decides which titles that are not part of the main
+// content are kept, and how they are named.
+type ExtrasPolicy struct {
+	Layout ExtrasLayout
+	// MinLength is the shortest title kept as an extra. Shorter
+	// titles are usually menus, logos and warnings.
+	MinLength time.Duration
+}
+
+func (p ExtrasPolicy) String() string {
+	if p.Layout == ExtrasDiscard {
+		return p.Layout.String()
+	}
+	return fmt.Sprintf("%s (at least %v)", p.Layout, p.MinLength)
+}
+
+// Extras returns every title on the disc that is not main content,
+// but should be kept according to the policy. Titles that are the
+// same as main content (e.g., the same playlist with a different
+// angle) are not extras.
+func (p *ExtrasPolicy) Extras(di *DiscInfo, main []*Score) ([]*Score, error) {
+	if p.Layout == ExtrasDiscard {
+		return nil, nil
+	}
+	mainIndices := make([]int, 0, len(main))
+	for _, score := range main {
+		mainIndices = append(mainIndices, score.TitleIndex)
+	}
+	result := make([]*Score, 0)
+	for index, title := range di.Titles {
+		if slices.Contains(mainIndices, index) {
+			continue
+		}
+		dur, err := parseHhMmSs(title.Duration)
+		if err != nil {
+			return nil, err
+		}
+		if dur < p.MinLength {
+			continue
+		}
+		duplicate := slices.ContainsFunc(mainIndices, func(i int) bool {
+			other := di.Titles[i]
+			return title.SegmentsMap != "" && title.SegmentsMap == other.SegmentsMap && title.Duration == other.Duration
+		})
+		if duplicate {
+			continue
+		}
+		result = append(result, &Score{
+			TitleIndex: index,
+			Duration:   dur,
+			Type:       ExtraType,
+			Playlist:   title.SourceFileName,
+		})
+	}
+	return result, nil
+}
+
+// Filename returns where the rip of the title should be placed,
+// relative to the destination directory. It must only be called if
+// the plan has an identity.
+//
+// If the plan has extras, the main content is placed in a folder of
+// its own, so that the extras can be placed next to it.
+func (p *Plan) Filename(title *Score) string {
+	name := fmt.Sprintf("%s (%d)", p.Identity.GetPrimaryTitle(), p.Identity.GetStartYear())
+	if len(p.Extras) == 0 || p.ExtrasPolicy.Layout == ExtrasDiscard {
+		return name + ".mkv"
+	}
+	if title.Type != ExtraType {
+		return path.Join(name, name+".mkv")
+	}
+	original := p.DiscInfo.Titles[title.TitleIndex].OutputFileName
+	stem := strings.TrimSuffix(original, filepath.Ext(original))
+	if p.ExtrasPolicy.Layout == ExtrasPlex {
+		return path.Join(name, stem+"-featurette.mkv")
+	}
+	return path.Join(name, "Extras", stem+".mkv")
+}
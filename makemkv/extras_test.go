@@ -0,0 +1,169 @@
+package makemkv
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+func TestExtras(t *testing.T) {
+	disc := &DiscInfo{
+		Titles: []TitleInfo{
+			// The main feature.
+			{GenericInfo: GenericInfo{Duration: "1:40:00", SegmentsMap: "1,2"}},
+			// The main feature, with a different angle.
+			{GenericInfo: GenericInfo{Duration: "1:40:00", SegmentsMap: "1,2"}},
+			// A featurette.
+			{GenericInfo: GenericInfo{Duration: "0:12:00", SegmentsMap: "3"}},
+			// A logo.
+			{GenericInfo: GenericInfo{Duration: "0:00:20", SegmentsMap: "4"}},
+			// A trailer.
+			{GenericInfo: GenericInfo{Duration: "0:02:30", SegmentsMap: "5"}},
+		},
+	}
+	main := []*Score{{TitleIndex: 0}}
+	tests := map[string]struct {
+		policy ExtrasPolicy
+		want   []int
+	}{
+		"discard": {
+			policy: ExtrasPolicy{Layout: ExtrasDiscard, MinLength: time.Minute},
+			want:   []int{},
+		},
+		"folder": {
+			policy: ExtrasPolicy{Layout: ExtrasFolder, MinLength: time.Minute},
+			want:   []int{2, 4},
+		},
+		"longer minimum": {
+			policy: ExtrasPolicy{Layout: ExtrasPlex, MinLength: 5 * time.Minute},
+			want:   []int{2},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			extras, err := tt.policy.Extras(disc, main)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int{}
+			for _, extra := range extras {
+				if extra.Type != ExtraType {
+					t.Errorf("got type %s, want %s", extra.Type, ExtraType)
+				}
+				got = append(got, extra.TitleIndex)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExtrasLayout(t *testing.T) {
+	for _, layout := range []ExtrasLayout{ExtrasDiscard, ExtrasFolder, ExtrasPlex} {
+		got, err := ParseExtrasLayout(layout.String())
+		if err != nil || got != layout {
+			t.Errorf("got %s (%+v), want %s", got, err, layout)
+		}
+	}
+	if _, err := ParseExtrasLayout("kodi"); err == nil {
+		t.Error("got no error for an unknown layout")
+	}
+}
+
+func TestPlanFilename(t *testing.T) {
+	main := &Score{TitleIndex: 0, Type: "movie"}
+	extra := &Score{TitleIndex: 1, Type: ExtraType}
+	newPlan := func(layout ExtrasLayout, extras []*Score) *Plan {
+		return &Plan{
+			Identity: pb.Title_builder{
+				PrimaryTitle: proto.String("Film"),
+				StartYear:    proto.Int32(2025),
+			}.Build(),
+			DiscInfo: &DiscInfo{
+				Titles: []TitleInfo{
+					{GenericInfo: GenericInfo{OutputFileName: "Film_t00.mkv"}},
+					{GenericInfo: GenericInfo{OutputFileName: "Film_t01.mkv"}},
+				},
+			},
+			RipTitles:    []*Score{main},
+			Extras:       extras,
+			ExtrasPolicy: ExtrasPolicy{Layout: layout},
+		}
+	}
+	tests := map[string]struct {
+		plan  *Plan
+		title *Score
+		want  string
+	}{
+		"no extras": {
+			plan:  newPlan(ExtrasFolder, nil),
+			title: main,
+			want:  "Film (2025).mkv",
+		},
+		"folder main": {
+			plan:  newPlan(ExtrasFolder, []*Score{extra}),
+			title: main,
+			want:  "Film (2025)/Film (2025).mkv",
+		},
+		"folder extra": {
+			plan:  newPlan(ExtrasFolder, []*Score{extra}),
+			title: extra,
+			want:  "Film (2025)/Extras/Film_t01.mkv",
+		},
+		"plex extra": {
+			plan:  newPlan(ExtrasPlex, []*Score{extra}),
+			title: extra,
+			want:  "Film (2025)/Film_t01-featurette.mkv",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.plan.Filename(tt.title); got != tt.want {
+				t.Errorf("got %+q, want %+q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakePlanExtras(t *testing.T) {
+	disc := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Name: "FILM",
+		},
+		Titles: []TitleInfo{
+			{GenericInfo: GenericInfo{Duration: "01:40:00"}},
+			{GenericInfo: GenericInfo{Duration: "00:12:00"}},
+		},
+	}
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	i := NewIdentifier(index)
+	i.ExtrasPolicy = ExtrasPolicy{Layout: ExtrasPlex, MinLength: time.Minute}
+	plan, err := i.MakePlan(disc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.RipTitles) != 1 || plan.RipTitles[0].TitleIndex != 0 {
+		t.Errorf("got %+v, want only title 0 as main content", plan.RipTitles)
+	}
+	if len(plan.Extras) != 1 || plan.Extras[0].TitleIndex != 1 {
+		t.Errorf("got %+v, want title 1 as an extra", plan.Extras)
+	}
+	if plan.ExtrasPolicy != i.ExtrasPolicy {
+		t.Errorf("got policy %s, want %s", plan.ExtrasPolicy, i.ExtrasPolicy)
+	}
+}
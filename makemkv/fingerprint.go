@@ -0,0 +1,220 @@
+package makemkv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/discid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func discInfoToDisc(discInfo *DiscInfo) *discid.Disc {
+	disc := &discid.Disc{
+		Name:   discInfo.VolumeName,
+		Titles: make([]*discid.Title, 0),
+	}
+	for _, t := range discInfo.Titles {
+		title := &discid.Title{
+			Filename: t.SourceFileName,
+			Duration: t.Duration,
+		}
+		if size, err := strconv.ParseInt(t.DiskSizeBytes, 10, 64); err == nil {
+			title.Size = size
+		}
+		disc.Titles = append(disc.Titles, title)
+	}
+	return disc
+}
+
+func discInfoToFingerprint(discInfo *DiscInfo, version discid.Version) ([]byte, error) {
+	return discid.FingerprintVersion(discInfoToDisc(discInfo), version)
+}
+
+// discInfoToSignature returns the encoded signature of the disc. A
+// disc without a signature can still be recognized by its
+// fingerprint, so failing to compute one is not an error.
+func discInfoToSignature(discInfo *DiscInfo) datatypes.JSON {
+	signature, err := discid.NewSignature(discInfoToDisc(discInfo))
+	if err != nil {
+		log.Printf("Could not compute the signature of %s: %v\n", discInfo.VolumeName, err)
+		return nil
+	}
+	b, err := json.Marshal(signature)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// findFingerprint returns the record of the disc's current-version
+// fingerprint, creating it if needed. If the disc was only recorded
+// with an earlier version, the new record is linked to the old one,
+// and the disc is not considered new.
+func findFingerprint(d *gorm.DB, discInfo *DiscInfo) (*db.DiscFingerprint, bool, error) {
+	fp, err := discInfoToFingerprint(discInfo, discid.CurrentVersion)
+	if err != nil {
+		return nil, false, err
+	}
+	signature := discInfoToSignature(discInfo)
+	result := &db.DiscFingerprint{}
+	err = d.Where("Fingerprint = ?", fp).First(result).Error
+	if err == nil {
+		// Discs seen before signatures were recorded get one
+		// now.
+		if len(result.Signature) == 0 {
+			result.Signature = signature
+			err = d.Save(result).Error
+		}
+		return result, false, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	var previous *db.DiscFingerprint
+	for _, version := range slices.Backward(discid.Versions) {
+		if version >= discid.CurrentVersion {
+			continue
+		}
+		old, err := discInfoToFingerprint(discInfo, version)
+		if err != nil {
+			return nil, false, err
+		}
+		candidate := &db.DiscFingerprint{}
+		err = d.Where("Fingerprint = ?", old).First(candidate).Error
+		if err == nil {
+			previous = candidate
+			break
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+
+	result = &db.DiscFingerprint{
+		Fingerprint: fp,
+		Name:        discInfo.Name,
+		VolumeName:  discInfo.VolumeName,
+		Version:     int(discid.CurrentVersion),
+		Signature:   signature,
+	}
+	if previous != nil {
+		result.PreviousID = &previous.ID
+	}
+	if err := d.Create(result).Error; err != nil {
+		return nil, false, err
+	}
+	return result, previous == nil, nil
+}
+
+// Refingerprinted is a fingerprint that was recomputed by
+// Refingerprint.
+type Refingerprinted struct {
+	Previous *db.DiscFingerprint
+	Current  *db.DiscFingerprint
+	// Created is set if the disc had not already been recorded
+	// with the new version.
+	Created bool
+}
+
+// Refingerprint recomputes every fingerprint older than version from
+// the `info` log of the first session that saw the disc, and links
+// the new fingerprints to the old ones. Discs that were analyzed
+// without makemkvcon have no log, and are skipped.
+func Refingerprint(d *gorm.DB, version discid.Version) ([]Refingerprinted, error) {
+	if !slices.Contains(discid.Versions, version) {
+		return nil, fmt.Errorf("unsupported fingerprint version %d", version)
+	}
+	// Read all of the rows up front, since parsing logs queries
+	// the database as well.
+	rows, err := db.GetAllDiscs(d)
+	if err != nil {
+		return nil, err
+	}
+	logs := make(map[uint]uint)
+	order := make([]uint, 0)
+	for rows.Next() {
+		var fingerprintID, logID uint
+		if err := rows.Scan(&fingerprintID, &logID); err != nil {
+			rows.Close() //nolint:errcheck
+			return nil, err
+		}
+		if _, ok := logs[fingerprintID]; !ok {
+			order = append(order, fingerprintID)
+			logs[fingerprintID] = logID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]Refingerprinted, 0)
+	for _, fingerprintID := range order {
+		previous := &db.DiscFingerprint{}
+		if err := d.First(previous, fingerprintID).Error; err != nil {
+			return nil, err
+		}
+		if previous.Version >= int(version) {
+			continue
+		}
+		discInfo, err := discInfoFromLog(d, logs[fingerprintID])
+		if err != nil {
+			return nil, err
+		}
+		if discInfo == nil {
+			log.Printf("Log %d of disc %d has no disc info, skipping\n", logs[fingerprintID], fingerprintID)
+			continue
+		}
+		fp, err := discInfoToFingerprint(discInfo, version)
+		if err != nil {
+			return nil, err
+		}
+		signature := discInfoToSignature(discInfo)
+		current := &db.DiscFingerprint{}
+		dbx := d.Where("Fingerprint = ?", fp).Attrs(db.DiscFingerprint{
+			Fingerprint: fp,
+			Name:        discInfo.Name,
+			VolumeName:  discInfo.VolumeName,
+			Version:     int(version),
+			PreviousID:  &previous.ID,
+			Signature:   signature,
+		}).FirstOrCreate(current)
+		if dbx.Error != nil {
+			return nil, dbx.Error
+		}
+		if current.PreviousID == nil && current.ID != previous.ID {
+			current.PreviousID = &previous.ID
+			if err := d.Save(current).Error; err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, Refingerprinted{
+			Previous: previous,
+			Current:  current,
+			Created:  dbx.RowsAffected != 0,
+		})
+	}
+	return result, nil
+}
+
+// discInfoFromLog parses a stored makemkvcon log, and returns the
+// DiscInfo in it, if any.
+func discInfoFromLog(d *gorm.DB, logID uint) (*DiscInfo, error) {
+	r, err := db.NewLogReader(d, logID)
+	if err != nil {
+		return nil, err
+	}
+	var result *DiscInfo
+	for msg := range NewParser(r).Stream() {
+		if discInfo, ok := msg.Parsed.(*DiscInfo); ok {
+			result = discInfo
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package makemkv
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/discid"
+	"gorm.io/gorm"
+)
+
+// testDiscInfo returns the DiscInfo of testdata/info.log.
+func testDiscInfo(t *testing.T) *DiscInfo {
+	f, err := os.Open(path.Join("testdata", "info.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	var result *DiscInfo
+	for msg := range NewParser(f).Stream() {
+		if discInfo, ok := msg.Parsed.(*DiscInfo); ok {
+			result = discInfo
+		}
+	}
+	if result == nil {
+		t.Fatal("info.log has no disc info")
+	}
+	return result
+}
+
+// recordVersion1 records the disc of testdata/info.log as it was
+// before fingerprints were versioned.
+func recordVersion1(t *testing.T, d *gorm.DB) *db.DiscFingerprint {
+	fp, err := discInfoToFingerprint(testDiscInfo(t), discid.Version1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &db.DiscFingerprint{Fingerprint: fp, VolumeName: "VOLUME_ID"}
+	if err := d.Create(result).Error; err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestAnalyzeLinksPreviousVersion(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := recordVersion1(t, d)
+	if old.Version != 1 {
+		t.Errorf("got version %d for a fingerprint without one, want 1", old.Version)
+	}
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	analysis, err := mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.New {
+		t.Error("analysis thinks a disc recorded with an earlier version is new")
+	}
+	current := db.DiscFingerprint{}
+	if err := d.Where("version = ?", discid.CurrentVersion).First(&current).Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.PreviousID == nil || *current.PreviousID != old.ID {
+		t.Errorf("got previous %v, want %d", current.PreviousID, old.ID)
+	}
+}
+
+func TestRefingerprint(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := recordVersion1(t, d)
+	session := db.Session{DiscFingerprintID: &old.ID}
+	if err := d.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	rawLog := db.MakeMkvLog{SessionID: session.ID, Args: []string{"--noscan", "info", "disc:0"}}
+	f, err := os.Open(path.Join("testdata", "info.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rawLog.Entry = append(rawLog.Entry, db.MakeMkvLogEntry{Entry: scanner.Text()})
+	}
+	if err := d.Create(&rawLog).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, created := range []bool{true, false} {
+		got, err := Refingerprint(d, discid.Version2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("got %d refingerprinted discs, want 1", len(got))
+		}
+		if got[0].Created != created || got[0].Previous.ID != old.ID {
+			t.Errorf("got %+v, want created: %t from %d", got[0], created, old.ID)
+		}
+		current := got[0].Current
+		if current.Version != 2 || current.PreviousID == nil || *current.PreviousID != old.ID || current.VolumeName != "VOLUME_ID" {
+			t.Errorf("got %+v, want a version 2 fingerprint of VOLUME_ID linked to %d", current, old.ID)
+		}
+		want, err := discInfoToFingerprint(testDiscInfo(t), discid.Version2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(current.Fingerprint) != string(want) {
+			t.Errorf("got fingerprint %x, want %x", current.Fingerprint, want)
+		}
+	}
+
+	// The disc would now be recognized without the old fingerprint.
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	analysis, err := mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.New {
+		t.Error("analysis thinks a refingerprinted disc is new")
+	}
+
+	if _, err := Refingerprint(d, discid.Version(0)); err == nil {
+		t.Error("Refingerprint unexpectedly succeeded with an unsupported version")
+	}
+}
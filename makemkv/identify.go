@@ -3,13 +3,20 @@ package makemkv
 import (
 	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"math"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/achernya/autorip/db"
 	"github.com/achernya/autorip/imdb"
+	"gorm.io/gorm"
 
 	pb "github.com/achernya/autorip/proto"
 )
@@ -20,9 +27,38 @@ type distribution struct {
 }
 
 var (
+	// classifiedTypes are the types of content the titles on a
+	// disc are classified as. These are IMDb title types, except
+	// that episodes of a series are classified as "tvEpisode"
+	// rather than "tvSeries".
+	classifiedTypes = []string{"movie", "tvEpisode", "tvMovie", "tvMiniSeries", "short", "video", "tvSpecial"}
+	// typeGroups are the IMDb title types that content of each
+	// classified type may be listed as. The runtimes of, e.g.,
+	// movies and TV movies are indistinguishable, and a concert
+	// film may be listed as either a video or a TV special. Every
+	// other title type (tvEpisode, tvPilot, videoGame) is never
+	// searched for.
+	typeGroups = map[string][]string{
+		"movie":        {"movie", "tvMovie", "video"},
+		"tvMovie":      {"tvMovie", "movie", "video"},
+		"video":        {"video", "movie", "tvMovie", "tvSpecial"},
+		"tvSpecial":    {"tvSpecial", "tvMovie", "video"},
+		"tvEpisode":    {"tvSeries", "tvMiniSeries"},
+		"tvMiniSeries": {"tvMiniSeries", "tvSeries"},
+		"short":        {"short", "tvShort", "video"},
+	}
+	// episodicTypes are IMDb title types whose runtime is that of
+	// a single episode, several of which are on each disc.
+	episodicTypes = []string{"tvSeries", "tvMiniSeries"}
+	// plausibleLikelihood is how likely, relative to the most
+	// likely type, another classified type must be to also be
+	// searched for.
+	plausibleLikelihood = 0.1
 	// These distributions were calcuated with some throwaway code
-	// on 2025-07-12 using the latest available IMDb data at the time.
-	dists = map[string]distribution{
+	// on 2025-07-12 using the latest available IMDb data at the
+	// time. They are only used if the index was built before
+	// distributions were computed along with it.
+	defaultDists = map[string]distribution{
 		"movie": {
 			mean:   88.96,
 			stddev: 27.35,
@@ -39,15 +75,47 @@ var (
 		"LOGICAL_VOLUME_ID",
 		"VOLUME_SET_ID",
 	}
-	// Acceptable ratios for a particular content to be off on the target lenght.
+	// Disc names sometimes include the release year, e.g.,
+	// "BLADE_RUNNER_1982".
+	yearPattern = regexp.MustCompile(`^(19|20)[0-9]{2}$`)
+	// Acceptable ratios for a particular content to be off on the
+	// target lenght, by IMDb title type. Content that is often
+	// edited differently for release (e.g., TV movies broadcast
+	// with commercials) is given more leeway.
 	ratios = map[string]float64{
-		"movie":    0.975,
-		"tvSeries": 0.9,
+		"movie":        0.975,
+		"tvMovie":      0.95,
+		"video":        0.9,
+		"tvSpecial":    0.9,
+		"tvSeries":     0.9,
+		"tvMiniSeries": 0.85,
+		"short":        0.8,
+		"tvShort":      0.8,
 	}
 )
 
+// maxCandidates is how many search results are compared to the disc
+// name before picking one.
+const maxCandidates = 20
+
 type Identifier struct {
+	// MinConfidence is the confidence below which a plan is
+	// marked as LowConfidence, and its rips are not renamed.
+	MinConfidence float64
+	// ExtrasPolicy decides which titles other than the main
+	// content are ripped.
+	ExtrasPolicy ExtrasPolicy
+	// StreamPolicy decides which streams of each title are
+	// saved.
+	StreamPolicy StreamPolicy
+	// IDService, if set, is asked for the identity of discs that
+	// were not identified here before searching the index.
+	IDService *IDClient
+
 	index imdb.GenericIndex
+	// dists are the runtime distributions of classifiedTypes,
+	// loaded from the index on first use.
+	dists map[string]distribution
 }
 
 func NewIdentifier(index imdb.GenericIndex) *Identifier {
@@ -148,6 +216,9 @@ type Score struct {
 	Type       string
 	Playlist   string
 	Likelihood float64
+	// Likelihoods is how likely each classified type is, relative
+	// to Type (for which it is 1).
+	Likelihoods map[string]float64
 }
 
 func gaussianPdf(sample, mean, stddev float64) float64 {
@@ -169,6 +240,39 @@ func parseHhMmSs(in string) (time.Duration, error) {
 	return t.Sub(sub), nil
 }
 
+// distributions returns the runtime distributions to classify titles
+// with, preferring those computed when the index was built.
+func (i *Identifier) distributions() (map[string]distribution, error) {
+	if i.dists != nil {
+		return i.dists, nil
+	}
+	var loaded map[string]*imdb.RuntimeDistribution
+	if i.index != nil {
+		var err error
+		loaded, err = i.index.RuntimeDistributions()
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.dists = make(map[string]distribution)
+	for _, titleType := range classifiedTypes {
+		d, ok := loaded[titleType]
+		// A distribution needs some spread to be usable.
+		if !ok || d.Count < 2 || d.StdDev == 0 {
+			continue
+		}
+		i.dists[titleType] = distribution{
+			mean:   d.Mean,
+			stddev: d.StdDev,
+		}
+	}
+	if len(i.dists) == 0 {
+		log.Println("Index has no runtime distributions, using defaults")
+		i.dists = defaultDists
+	}
+	return i.dists, nil
+}
+
 // DiscLikelyContains returns a sorted-descending list containing a
 // score, type, and index for the titles on the disc. Note that this
 // function will return "tvEpisode", not "tvSeries" as it's
@@ -177,6 +281,10 @@ func parseHhMmSs(in string) (time.Duration, error) {
 // The input to this function should be the filtered map produced by
 // FilterDiscInfo.
 func (i *Identifier) DiscLikelyContains(titles map[int]*TitleInfo) ([]*Score, error) {
+	dists, err := i.distributions()
+	if err != nil {
+		return nil, err
+	}
 	scores := make([]*Score, 0)
 	for index, title := range titles {
 		dur, err := parseHhMmSs(title.Duration)
@@ -203,14 +311,24 @@ func (i *Identifier) DiscLikelyContains(titles map[int]*TitleInfo) ([]*Score, er
 		})
 		// result[0] now contains the smallest PDF, and the
 		// last element the maximum. The likelihood is the
-		// ratio between the two.
+		// ratio between the maximum and the runner-up, so
+		// that it is close to 1 if the top two types are
+		// hard to tell apart.
 		last := len(result) - 1
+		runnerUp := max(0, last-1)
+		likelihoods := make(map[string]float64)
+		for _, r := range result {
+			if result[last].value > 0 {
+				likelihoods[r.name] = r.value / result[last].value
+			}
+		}
 		scores = append(scores, &Score{
-			TitleIndex: index,
-			Duration:   dur,
-			Type:       result[last].name,
-			Playlist:   title.SourceFileName,
-			Likelihood: result[last].value / result[0].value,
+			TitleIndex:  index,
+			Duration:    dur,
+			Type:        result[last].name,
+			Playlist:    title.SourceFileName,
+			Likelihood:  result[last].value / result[runnerUp].value,
+			Likelihoods: likelihoods,
 		})
 	}
 	slices.SortFunc(scores, func(a, b *Score) int {
@@ -235,7 +353,19 @@ func (i *Identifier) DiscLikelyContains(titles map[int]*TitleInfo) ([]*Score, er
 	return scores, nil
 }
 
+// XrefImdb returns the most likely identity of the disc, or nil if it
+// could not be identified.
 func (i *Identifier) XrefImdb(di *DiscInfo, scores []*Score) (*pb.Title, error) {
+	candidates, err := i.Candidates(di, scores)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	return candidates[0].Title, nil
+}
+
+// Candidates returns the possible identities of the disc, sorted by
+// descending confidence.
+func (i *Identifier) Candidates(di *DiscInfo, scores []*Score) ([]*Candidate, error) {
 	// If there are no scores for titles on the disc, there's nothing to compare.
 	if len(scores) == 0 {
 		return nil, nil
@@ -253,50 +383,139 @@ func (i *Identifier) XrefImdb(di *DiscInfo, scores []*Score) (*pb.Title, error)
 	}
 	// volume names have '_' instead of ' ', but we need the
 	// search terms to be seperated by spaces to work well.
-	query := strings.ReplaceAll(name, "_", " ")
-	// Also escape `:` since that will be a field selector
-	query = strings.ReplaceAll(query, ":", "\\:")
-	// Also escape `-` since that is a negation character.
-	query = strings.ReplaceAll(query, "-", "\\-")
-	log.Printf("Searching %+q\n", query)
+	text := strings.ReplaceAll(name, "_", " ")
+	// Assume that the classifier was (roughly) correct, and only
+	// look for entries of a plausible type that have a "similar
+	// enough" runtime. That should be enough to distinguish
+	// between remakes.
+	typeLikelihoods := plausibleTypes(scores[0])
+	titleTypes := slices.Sorted(maps.Keys(typeLikelihoods))
+	ratio := 1.0
+	for _, titleType := range titleTypes {
+		ratio = min(ratio, ratios[titleType])
+	}
+	minutes := scores[0].Duration.Minutes()
+	query := &imdb.TitleQuery{
+		Text:       text,
+		TitleTypes: titleTypes,
+		MinRuntime: int32(math.Ceil(minutes * ratio)),
+		MaxRuntime: int32(math.Floor(minutes / ratio)),
+		Year:       yearOf(text),
+		Size:       maxCandidates,
+	}
+	// Disc names are often truncated, abbreviated or misspelled,
+	// so if nothing matches exactly, progressively relax the
+	// search.
+	for _, match := range []imdb.MatchMode{imdb.MatchExact, imdb.MatchFuzzy, imdb.MatchPrefix} {
+		query.Match = match
+		log.Printf("Searching %+v\n", query)
+		results, err := i.search(query)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			continue
+		}
+		// The query allows for the most lenient ratio of any
+		// of the types, so apply the ratio of each type now.
+		results = slices.DeleteFunc(results, func(result *pb.Result) bool {
+			entry := result.GetEntry()
+			got := runtimeRatio(entry.GetRuntimeMinutes(), scores[0].Duration)
+			if got < ratios[entry.GetTitleType()] {
+				log.Printf("Skipping %s, bad ratio %f < %f for %s\n", entry.GetPrimaryTitle(), got, ratios[entry.GetTitleType()], entry.GetTitleType())
+				return true
+			}
+			return false
+		})
+		if len(results) == 0 {
+			continue
+		}
+		ctx := &candidateContext{
+			name:            text,
+			year:            query.Year,
+			score:           scores[0],
+			typeLikelihoods: typeLikelihoods,
+			match:           match,
+		}
+		for _, result := range results {
+			ctx.maxScore = max(ctx.maxScore, result.GetScore())
+		}
+		candidates := make([]*Candidate, 0, len(results))
+		for _, result := range results {
+			candidates = append(candidates, newCandidate(ctx, result))
+		}
+		// Equally confident candidates keep the order of the
+		// index, which prefers more popular titles.
+		slices.SortStableFunc(candidates, func(a, b *Candidate) int {
+			return cmp.Compare(b.Confidence, a.Confidence)
+		})
+		best := candidates[0]
+		log.Printf("Found [%s] %s (%s match, confidence %.2f)\n", best.Title.GetTConst(), best.Title.GetPrimaryTitle(), match, best.Confidence)
+		return candidates, nil
+	}
+	return nil, nil
+}
+
+// plausibleTypes returns the IMDb title types the content may be
+// listed as, and how likely each of them is relative to the most
+// likely one.
+func plausibleTypes(score *Score) map[string]float64 {
+	likelihoods := score.Likelihoods
+	if likelihoods == nil {
+		likelihoods = map[string]float64{score.Type: 1}
+	}
+	result := make(map[string]float64)
+	for classified, likelihood := range likelihoods {
+		if likelihood < plausibleLikelihood {
+			continue
+		}
+		for _, titleType := range typeGroups[classified] {
+			result[titleType] = max(result[titleType], likelihood)
+		}
+	}
+	return result
+}
+
+// runtimeRatio is the ratio between the shorter and the longer of the
+// two runtimes, or 0 if either is unknown.
+func runtimeRatio(minutes int32, duration time.Duration) float64 {
+	runtime := time.Duration(minutes) * time.Minute
+	if runtime <= 0 || duration <= 0 {
+		return 0
+	}
+	return float64(min(runtime, duration)) / float64(max(runtime, duration))
+}
+
+// search returns all of the results of the query, in the order
+// returned by the index.
+func (i *Identifier) search(query *imdb.TitleQuery) ([]*pb.Result, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	ch, err := i.index.Search(ctx, query)
+	defer cancel()
+	ch, err := i.index.Query(ctx, query)
 	if err != nil {
-		cancel()
 		return nil, err
 	}
-	defer cancel()
-	wantType := scores[0].Type
-	if wantType == "tvEpisode" {
-		wantType = "tvSeries"
-	}
+	result := make([]*pb.Result, 0)
 	for info := range ch {
-		// For now, we'll use a very simple algorithm: assume
-		// that the classifier for movie vs tvEpisode was
-		// correct, then find the first entry that has a
-		// "similar enough" runtime. That should be enough to
-		// distinguish between remakes.
-		entry := info.GetEntry()
-		if entry.GetTitleType() == "tvEpisode" {
-			// The search engine returns individual
-			// episodes, but we're interested in the
-			// container series at this point.
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// yearOf returns the first plausible release year in a disc name, or
+// 0 if there is none.
+func yearOf(text string) int32 {
+	for _, field := range strings.Fields(text) {
+		if !yearPattern.MatchString(field) {
 			continue
 		}
-		if entry.GetTitleType() != wantType {
-			log.Printf("Skipping %s (got %s, want %s)\n", entry.GetTConst(), entry.GetTitleType(), wantType)
+		year, err := strconv.Atoi(field)
+		if err != nil {
 			continue
 		}
-		durations := []time.Duration{time.Minute * time.Duration(entry.GetRuntimeMinutes()), scores[0].Duration}
-		slices.Sort(durations)
-		ratio := float64(durations[0]) / float64(durations[1])
-		if ratio > ratios[wantType] {
-			log.Printf("Found [%s] %s\n", entry.GetTConst(), entry.GetPrimaryTitle())
-			return entry, nil
-		}
-		log.Printf("Skipping %s, bad ratio %f < %f\n", entry.GetPrimaryTitle(), ratio, ratios[wantType])
+		return int32(year)
 	}
-	return nil, nil
+	return 0
 }
 
 func (i *Identifier) RemoveOutliers(scores []*Score, runtime int32) []*Score {
@@ -317,39 +536,206 @@ func (i *Identifier) RemoveOutliers(scores []*Score, runtime int32) []*Score {
 }
 
 type Plan struct {
-	Identity  *pb.Title
-	DiscInfo  *DiscInfo
-	RipTitles []*Score
+	Identity *pb.Title
+	// Candidates are all of the possible identities considered,
+	// sorted by descending confidence. Identity is the first.
+	Candidates []*Candidate
+	// LowConfidence is set if the Identity is not trustworthy
+	// enough to rename the rips automatically.
+	LowConfidence bool
+	DiscInfo      *DiscInfo
+	RipTitles     []*Score
+	// Extras are ripped after RipTitles, according to
+	// ExtrasPolicy.
+	Extras       []*Score
+	ExtrasPolicy ExtrasPolicy
+	// Streams are the decisions of StreamPolicy for every stream
+	// of every title to be ripped, by title index.
+	Streams      map[int][]StreamDecision
+	StreamPolicy StreamPolicy
+	// Snapshot is the provenance of the index the identity was
+	// found in, if known.
+	Snapshot *imdb.Snapshot
+	// Known is the identification of the disc, if it was seen
+	// before, or is known to the ID service. If set, its identity
+	// is the first candidate.
+	Known *db.Identification
+	// Similar is the most similar disc seen before, if any. If it
+	// was identified with enough confidence, and the disc is not
+	// Known, its identity is the first candidate.
+	Similar *SimilarDisc
 }
 
 func (i *Identifier) MakePlan(discInfo *DiscInfo) (*Plan, error) {
+	return i.MakePlanFor(&Analysis{DiscInfo: discInfo})
+}
+
+// MakePlanFor is like MakePlan, but if the disc, or a similar disc,
+// was identified before, that identity is preferred over any found by
+// searching.
+func (i *Identifier) MakePlanFor(analysis *Analysis) (*Plan, error) {
+	discInfo := analysis.DiscInfo
 	titles := i.FilterDiscInfo(discInfo)
 	likely, err := i.DiscLikelyContains(titles)
 	if err != nil {
 		return nil, err
 	}
-	identity, err := i.XrefImdb(discInfo, likely)
+	candidates, err := i.Candidates(discInfo, likely)
+	if err != nil {
+		return nil, err
+	}
+	known, detail := analysis.Identification, ""
+	if known != nil {
+		detail = describeIdentification(known)
+	} else if i.IDService != nil && len(analysis.Fingerprint) > 0 {
+		known, detail = i.lookupIDService(analysis.Fingerprint)
+	}
+	if known != nil {
+		candidates, err = i.preferIdentity(candidates, known.TConst, Factor{
+			Name:   "known",
+			Value:  1,
+			Weight: 1,
+			Detail: detail,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if similar := analysis.Similar; similar != nil && similar.Identification != nil && !similar.Identification.LowConfidence {
+		candidates, err = i.preferIdentity(candidates, similar.Identification.TConst, Factor{
+			Name:   "similar",
+			Value:  similar.Similarity,
+			Weight: 1,
+			Detail: similar.String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	snapshot, err := i.index.Snapshot()
 	if err != nil {
 		return nil, err
 	}
+	var identity *pb.Title
+	if len(candidates) > 0 {
+		identity = candidates[0].Title
+	}
 	result := &Plan{
-		Identity:  identity,
-		DiscInfo:  discInfo,
-		RipTitles: likely,
+		Identity:   identity,
+		Candidates: candidates,
+		DiscInfo:   discInfo,
+		RipTitles:  likely,
+		Snapshot:   snapshot,
+		Known:      known,
+		Similar:    analysis.Similar,
+	}
+	if len(candidates) > 0 && candidates[0].Confidence < i.MinConfidence {
+		log.Printf("Confidence %.2f is below %.2f, rips will not be renamed\n", candidates[0].Confidence, i.MinConfidence)
+		result.LowConfidence = true
 	}
-	if identity.GetTitleType() == "movie" {
-		// For a movie, only the first title will be
-		// ripped.
+	if identity != nil && !slices.Contains(episodicTypes, identity.GetTitleType()) {
+		// For a movie (or anything else that is not a
+		// series), only the first title will be ripped.
 
 		// TODO(achernya): deal with the Inception edge case
-		// here and in XrefImdb.
-		result.RipTitles = result.RipTitles[:1]
+		// here and in XrefImdb. A known identity is set even
+		// if there is nothing to rip.
+		result.RipTitles = result.RipTitles[:min(1, len(result.RipTitles))]
 	} else {
-		// For tvSeries, remove any outliers
+		// For series, remove any outliers
 		result.RipTitles = i.RemoveOutliers(result.RipTitles, identity.GetRuntimeMinutes())
 	}
-	for _, title := range result.RipTitles {
+	if known != nil && identity.GetTConst() == known.TConst {
+		// Rip the same edition as before, rather than
+		// whichever the heuristics prefer.
+		edition, err := chooseEdition(likely, known)
+		if err != nil {
+			return nil, err
+		}
+		if len(edition) > 0 {
+			log.Printf("Ripping the same %d title(s) as when the disc was identified before\n", len(edition))
+			result.RipTitles = edition
+		}
+	}
+	extras, err := i.ExtrasPolicy.Extras(discInfo, result.RipTitles)
+	if err != nil {
+		return nil, err
+	}
+	result.Extras = extras
+	result.ExtrasPolicy = i.ExtrasPolicy
+	result.StreamPolicy = i.StreamPolicy
+	result.Streams = make(map[int][]StreamDecision)
+	for _, title := range slices.Concat(result.RipTitles, result.Extras) {
 		log.Printf("Plan to rip title %d (type: %s; duration %v)", title.TitleIndex, title.Type, title.Duration)
+		result.Streams[title.TitleIndex] = i.StreamPolicy.Select(&discInfo.Titles[title.TitleIndex])
+		for _, decision := range result.Streams[title.TitleIndex] {
+			log.Printf("  %s\n", decision)
+		}
+	}
+	return result, nil
+}
+
+// preferIdentity puts the title first among the candidates, with the
+// value of the factor as its confidence. This is used for identities
+// that were found before, for this disc or a similar one.
+func (i *Identifier) preferIdentity(candidates []*Candidate, tconst string, factor Factor) ([]*Candidate, error) {
+	title, err := i.index.Lookup(context.Background(), tconst)
+	if errors.Is(err, imdb.ErrNotFound) {
+		log.Printf("%s is no longer in the index, not reusing it\n", tconst)
+		return candidates, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []*Candidate{{
+		Title:      title,
+		Match:      imdb.MatchExact,
+		Confidence: factor.Value,
+		Factors:    []Factor{factor},
+	}}
+	for _, candidate := range candidates {
+		if candidate.Title.GetTConst() != title.GetTConst() {
+			result = append(result, candidate)
+		}
 	}
 	return result, nil
 }
+
+// lookupIDService asks the ID service for the identity of the disc.
+// The service being unavailable is not worth failing over, since the
+// disc can still be identified by searching.
+func (i *Identifier) lookupIDService(fingerprint []byte) (*db.Identification, string) {
+	shared, err := i.IDService.Lookup(context.Background(), fingerprint)
+	if err != nil {
+		log.Printf("Could not look up the disc in %s: %v\n", i.IDService.URL, err)
+		return nil, ""
+	}
+	if shared == nil {
+		return nil, ""
+	}
+	if _, err := parseEdition(shared.Edition); err != nil {
+		log.Printf("Ignoring the identity of the disc from %s: %v\n", i.IDService.URL, err)
+		return nil, ""
+	}
+	identification := &db.Identification{
+		Model:        gorm.Model{CreatedAt: shared.Identified},
+		TConst:       shared.TConst,
+		TitleType:    shared.TitleType,
+		PrimaryTitle: shared.PrimaryTitle,
+		StartYear:    shared.StartYear,
+		Confidence:   shared.Confidence,
+		Edition:      shared.Edition,
+	}
+	return identification, fmt.Sprintf("%s by %s", describeIdentification(identification), i.IDService.URL)
+}
+
+// describeIdentification explains where a known identification came
+// from.
+func describeIdentification(identification *db.Identification) string {
+	result := fmt.Sprintf("identified as %s (%d) [%s] on %s",
+		identification.PrimaryTitle, identification.StartYear, identification.TConst,
+		identification.CreatedAt.Format("2006-01-02"))
+	if len(identification.ImportedFrom) > 0 {
+		result += fmt.Sprintf(" by %s", KeyID(identification.ImportedFrom))
+	}
+	return result
+}
@@ -2,9 +2,12 @@ package makemkv
 
 import (
 	"context"
+	"reflect"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/achernya/autorip/imdb"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
 
@@ -307,6 +310,7 @@ func TestDiscLikelyContains(t *testing.T) {
 
 type fakeIndex struct {
 	results []*pb.Result
+	dists   map[string]*imdb.RuntimeDistribution
 }
 
 func (f *fakeIndex) Build() error {
@@ -324,10 +328,48 @@ func (f *fakeIndex) Search(ctx context.Context, query string) (<-chan *pb.Result
 	return ch, nil
 }
 
+// Query applies the filters of the query, but ignores the text, since
+// every result is assumed to match.
+func (f *fakeIndex) Query(ctx context.Context, query *imdb.TitleQuery) (<-chan *pb.Result, error) {
+	ch := make(chan *pb.Result, len(f.results))
+	for _, result := range f.results {
+		entry := result.GetEntry()
+		if len(query.TitleTypes) > 0 && !slices.Contains(query.TitleTypes, entry.GetTitleType()) {
+			continue
+		}
+		if query.MinRuntime > 0 && entry.GetRuntimeMinutes() < query.MinRuntime {
+			continue
+		}
+		if query.MaxRuntime > 0 && entry.GetRuntimeMinutes() > query.MaxRuntime {
+			continue
+		}
+		ch <- result
+	}
+	close(ch)
+	return ch, nil
+}
+
 func (f *fakeIndex) SearchJSON(query string, maxResults int) (string, error) {
 	return "", nil
 }
 
+func (f *fakeIndex) Lookup(ctx context.Context, tconst string) (*pb.Title, error) {
+	for _, result := range f.results {
+		if result.GetEntry().GetTConst() == tconst {
+			return result.GetEntry(), nil
+		}
+	}
+	return nil, imdb.ErrNotFound
+}
+
+func (f *fakeIndex) RuntimeDistributions() (map[string]*imdb.RuntimeDistribution, error) {
+	return f.dists, nil
+}
+
+func (f *fakeIndex) Snapshot() (*imdb.Snapshot, error) {
+	return nil, nil
+}
+
 func (f *fakeIndex) Close() {
 }
 
@@ -455,6 +497,113 @@ func TestXrefImdb(t *testing.T) {
 			},
 			expected: 1,
 		},
+		"concert video": {
+			disc: &DiscInfo{},
+			scores: []*Score{
+				{
+					Duration:    92 * time.Minute,
+					Type:        "movie",
+					Likelihoods: map[string]float64{"movie": 1, "video": 0.9},
+				},
+			},
+			index: &fakeIndex{
+				results: []*pb.Result{
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							TitleType:      proto.String("tvEpisode"),
+							RuntimeMinutes: proto.Int32(92),
+						}.Build(),
+					}.Build(),
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							TitleType:      proto.String("video"),
+							RuntimeMinutes: proto.Int32(100),
+						}.Build(),
+					}.Build(),
+				},
+			},
+			expected: 1,
+		},
+		"per-type ratio": {
+			disc: &DiscInfo{},
+			scores: []*Score{
+				{
+					Duration: 95 * time.Minute,
+					Type:     "movie",
+				},
+			},
+			index: &fakeIndex{
+				results: []*pb.Result{
+					// 95/100 is too far off for a movie,
+					// but not for a TV movie.
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							TitleType:      proto.String("movie"),
+							RuntimeMinutes: proto.Int32(100),
+						}.Build(),
+					}.Build(),
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							TitleType:      proto.String("tvMovie"),
+							RuntimeMinutes: proto.Int32(100),
+						}.Build(),
+					}.Build(),
+				},
+			},
+			expected: 1,
+		},
+		"miniseries": {
+			disc: &DiscInfo{},
+			scores: []*Score{
+				{
+					Duration: 55 * time.Minute,
+					Type:     "tvMiniSeries",
+				},
+			},
+			index: &fakeIndex{
+				results: []*pb.Result{
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							TitleType:      proto.String("tvMiniSeries"),
+							RuntimeMinutes: proto.Int32(60),
+						}.Build(),
+					}.Build(),
+				},
+			},
+			expected: 0,
+		},
+		"most similar name": {
+			disc: &DiscInfo{
+				GenericInfo: GenericInfo{
+					Name: "LOTR_FOTR",
+				},
+			},
+			scores: []*Score{
+				{
+					Duration: 178 * time.Minute,
+					Type:     "movie",
+				},
+			},
+			index: &fakeIndex{
+				results: []*pb.Result{
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							PrimaryTitle:   proto.String("The Lord of the Rings: The Two Towers"),
+							TitleType:      proto.String("movie"),
+							RuntimeMinutes: proto.Int32(179),
+						}.Build(),
+					}.Build(),
+					pb.Result_builder{
+						Entry: pb.Title_builder{
+							PrimaryTitle:   proto.String("The Lord of the Rings: The Fellowship of the Ring"),
+							TitleType:      proto.String("movie"),
+							RuntimeMinutes: proto.Int32(178),
+						}.Build(),
+					}.Build(),
+				},
+			},
+			expected: 1,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -481,3 +630,85 @@ func TestXrefImdb(t *testing.T) {
 		})
 	}
 }
+
+func TestYearOf(t *testing.T) {
+	tests := map[string]int32{
+		"BLADE RUNNER 1982": 1982,
+		"1917":              1917,
+		"STAR WARS":         0,
+		"SEASON 2 DISC 1":   0,
+		"MOVIE 3000":        0,
+		"A 2001 THING 2010": 2001,
+	}
+	for input, want := range tests {
+		if got := yearOf(input); got != want {
+			t.Errorf("yearOf(%+q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestDiscLikelyContainsUsesIndexDistributions(t *testing.T) {
+	// With these distributions, a 75 minute title is more likely
+	// to be an episode than a movie, unlike with the defaults.
+	index := &fakeIndex{
+		dists: map[string]*imdb.RuntimeDistribution{
+			"movie":     {Count: 100, Mean: 120, StdDev: 10},
+			"tvEpisode": {Count: 100, Mean: 55, StdDev: 10},
+		},
+	}
+	titles := map[int]*TitleInfo{
+		0: {
+			GenericInfo: GenericInfo{
+				Duration: "1:15:00",
+			},
+		},
+	}
+	for _, tt := range []struct {
+		identifier *Identifier
+		want       string
+	}{
+		{NewIdentifier(index), "tvEpisode"},
+		{NewIdentifier(&fakeIndex{}), "movie"},
+	} {
+		scores, err := tt.identifier.DiscLikelyContains(titles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scores) != 1 || scores[0].Type != tt.want {
+			t.Errorf("got %+v, want %s", scores[0], tt.want)
+		}
+	}
+}
+
+func TestPlausibleTypes(t *testing.T) {
+	tests := map[string]struct {
+		score *Score
+		want  map[string]float64
+	}{
+		"episode": {
+			score: &Score{Type: "tvEpisode"},
+			want:  map[string]float64{"tvSeries": 1, "tvMiniSeries": 1},
+		},
+		"implausible types are excluded": {
+			score: &Score{
+				Type:        "short",
+				Likelihoods: map[string]float64{"short": 1, "tvEpisode": 0.5, "movie": 0.01},
+			},
+			want: map[string]float64{"short": 1, "tvShort": 1, "video": 1, "tvSeries": 0.5, "tvMiniSeries": 0.5},
+		},
+		"overlapping groups": {
+			score: &Score{
+				Type:        "movie",
+				Likelihoods: map[string]float64{"movie": 1, "tvSpecial": 0.2},
+			},
+			want: map[string]float64{"movie": 1, "tvMovie": 1, "video": 1, "tvSpecial": 0.2},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := plausibleTypes(tt.score); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package makemkv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/achernya/autorip/db"
+	"gorm.io/gorm"
+)
+
+// maxSubmission is the largest export accepted by the ID service.
+const maxSubmission = 64 << 20
+
+// Submitted is the outcome of submitting a single disc to the ID
+// service.
+type Submitted struct {
+	VolumeName string
+	TConst     string
+	// Local is the identity the service already had for the disc,
+	// if any.
+	Local  string `json:",omitempty"`
+	Status string
+}
+
+// NewIDServer returns the handler of the ID service, which shares
+// the identities of the discs in the database over HTTP:
+//
+//   - GET /v1/discs/{fingerprint} returns the SharedDisc with the hex
+//     encoded fingerprint, or 404 if the disc was never identified.
+//   - POST /v1/discs merges a file written by Export, which must be
+//     signed by one of the trusted keys, as Import does. Conflicting
+//     identities are reported, and never replaced.
+func NewIDServer(d *gorm.DB, trusted []ed25519.PublicKey) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/discs/{fingerprint}", func(w http.ResponseWriter, r *http.Request) {
+		fingerprint, err := hex.DecodeString(r.PathValue("fingerprint"))
+		if err != nil {
+			http.Error(w, "fingerprint must be hex encoded", http.StatusBadRequest)
+			return
+		}
+		fp := &db.DiscFingerprint{}
+		err = d.Where("Fingerprint = ?", fingerprint).First(fp).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		identification, err := latestIdentification(d, fp)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		if identification == nil || identification.LowConfidence {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, newSharedDisc(fp, identification))
+	})
+	mux.HandleFunc("POST /v1/discs", func(w http.ResponseWriter, r *http.Request) {
+		imported, err := Import(d, http.MaxBytesReader(w, r.Body, maxSubmission), trusted, false)
+		if errors.Is(err, ErrMalformedExport) || errors.Is(err, ErrUntrustedKey) || errors.Is(err, ErrBadSignature) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		result := make([]Submitted, 0, len(imported))
+		for _, disc := range imported {
+			submitted := Submitted{
+				VolumeName: disc.Disc.VolumeName,
+				TConst:     disc.Disc.TConst,
+				Status:     disc.Status.String(),
+			}
+			if disc.Local != nil {
+				submitted.Local = disc.Local.TConst
+			}
+			result = append(result, submitted)
+		}
+		writeJSON(w, result)
+	})
+	return mux
+}
+
+func serverError(w http.ResponseWriter, err error) {
+	log.Printf("ID service error: %v\n", err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("ID service error: %v\n", err)
+	}
+}
+
+// IDClient queries an ID service started by NewIDServer.
+type IDClient struct {
+	// URL is the base URL of the service, e.g.,
+	// "http://rips.example.com:8765".
+	URL    string
+	Client *http.Client
+}
+
+func NewIDClient(url string) *IDClient {
+	return &IDClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Lookup returns the identity of the disc with the fingerprint, or
+// nil if the service does not know it.
+func (c *IDClient) Lookup(ctx context.Context, fingerprint []byte) (*SharedDisc, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"/v1/discs/"+hex.EncodeToString(fingerprint), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	result := &SharedDisc{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Submit sends a file written by Export to the service.
+func (c *IDClient) Submit(ctx context.Context, export io.Reader) ([]Submitted, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/v1/discs", export)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	result := []Submitted{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(b))
+}
@@ -0,0 +1,222 @@
+package makemkv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/achernya/autorip/discid"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+func TestIDService(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := openTestDB(t)
+	recordIdentified(t, source, "tt0000001")
+	export := &bytes.Buffer{}
+	if _, err := Export(source, key, export); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := discInfoToFingerprint(testDiscInfo(t), discid.CurrentVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewIDServer(openTestDB(t), []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}))
+	defer server.Close()
+	client := NewIDClient(server.URL + "/")
+	ctx := context.Background()
+
+	disc, err := client.Lookup(ctx, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disc != nil {
+		t.Errorf("got %+v before anything was submitted, want nil", disc)
+	}
+
+	for _, want := range []string{"created", "unchanged"} {
+		submitted, err := client.Submit(ctx, bytes.NewReader(export.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(submitted) != 1 || submitted[0].Status != want || submitted[0].TConst != "tt0000001" {
+			t.Errorf("got %+v, want tt0000001 %s", submitted, want)
+		}
+	}
+	disc, err = client.Lookup(ctx, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disc == nil || disc.TConst != "tt0000001" || disc.VolumeName != "VOLUME_ID" {
+		t.Errorf("got %+v, want VOLUME_ID identified as tt0000001", disc)
+	}
+
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted := &bytes.Buffer{}
+	if _, err := Export(source, other, untrusted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Submit(ctx, untrusted); err == nil || !strings.Contains(err.Error(), "not trusted") {
+		t.Errorf("got error %v, want the untrusted key rejected", err)
+	}
+}
+
+func TestIDServiceRejects(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := openTestDB(t)
+	recordIdentified(t, source, "tt0000001")
+	export := &bytes.Buffer{}
+	if _, err := Export(source, key, export); err != nil {
+		t.Fatal(err)
+	}
+	untrusted := &bytes.Buffer{}
+	if _, err := Export(source, other, untrusted); err != nil {
+		t.Fatal(err)
+	}
+	signed := signedExport{}
+	if err := json.Unmarshal(export.Bytes(), &signed); err != nil {
+		t.Fatal(err)
+	}
+	signed.Payload = bytes.Replace(signed.Payload, []byte("tt0000001"), []byte("tt0000002"), 1)
+	tampered, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := openTestDB(t)
+	server := httptest.NewServer(NewIDServer(d, []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}))
+	defer server.Close()
+	submit := func(body []byte) (int, string) {
+		resp, err := http.Post(server.URL+"/v1/discs", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() //nolint:errcheck
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+	for name, body := range map[string][]byte{
+		"untrusted": untrusted.Bytes(),
+		"tampered":  tampered,
+		"malformed": []byte("not an export"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if status, _ := submit(body); status != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", status, http.StatusBadRequest)
+			}
+		})
+	}
+
+	// Failing to store a valid export is the server's fault, and
+	// the details are not sent to the client.
+	sqlDB, err := d.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		t.Fatal(err)
+	}
+	status, body := submit(export.Bytes())
+	if status != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", status, http.StatusInternalServerError)
+	}
+	if strings.TrimSpace(body) != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("got body %+q, want no details", body)
+	}
+}
+
+func TestMakePlanForUsesIDService(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := openTestDB(t)
+	recordIdentified(t, d, "tt0000002")
+	server := httptest.NewServer(NewIDServer(d, []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}))
+	defer server.Close()
+	fingerprint, err := discInfoToFingerprint(testDiscInfo(t), discid.CurrentVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disc := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Name: "FILM",
+		},
+		Titles: []TitleInfo{
+			{
+				GenericInfo: GenericInfo{
+					Duration: "01:40:00",
+				},
+			},
+		},
+	}
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000001"),
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000002"),
+					PrimaryTitle:   proto.String("Film 2"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	i := NewIdentifier(index)
+	i.IDService = NewIDClient(server.URL)
+	plan, err := i.MakePlanFor(&Analysis{DiscInfo: disc, Fingerprint: fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Identity.GetTConst() != "tt0000002" || plan.Known == nil {
+		t.Fatalf("got identity %s, want tt0000002 from the ID service", plan.Identity.GetTConst())
+	}
+	if detail := plan.Candidates[0].Factors[0].Detail; !strings.Contains(detail, server.URL) {
+		t.Errorf("got %s, want a mention of %s", detail, server.URL)
+	}
+
+	// Discs are still identified by searching if the service is
+	// unavailable.
+	server.Close()
+	plan, err = i.MakePlanFor(&Analysis{DiscInfo: disc, Fingerprint: fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Identity.GetTConst() != "tt0000001" || plan.Known != nil {
+		t.Errorf("got identity %s, want tt0000001 from searching", plan.Identity.GetTConst())
+	}
+}
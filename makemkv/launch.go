@@ -2,6 +2,7 @@ package makemkv
 
 import (
 	"context"
+	"fmt"
 	"os/exec"
 	"slices"
 )
@@ -19,7 +20,7 @@ var (
 		// substantially increase processing time for discs
 		// tha thave a lot of short playlists, as `makemkvcon`
 		// tries to access each one and process some metadata.
-		"--minlength=120",
+		fmt.Sprintf("--minlength=%d", int(minLength.Seconds())),
 		// Enable "robot-mode" output. This causes
 		// `makemkvcon` to produce messages in a parseable
 		// format, which this tool needs to be able to do its
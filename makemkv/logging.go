@@ -0,0 +1,93 @@
+package makemkv
+
+import (
+	"fmt"
+)
+
+// ProgressLogging is how progress updates (PRGV lines), which make up
+// most of the log of a rip, are stored.
+type ProgressLogging int
+
+const (
+	// ProgressKeep stores every progress update.
+	ProgressKeep ProgressLogging = iota
+	// ProgressDrop stores no progress updates.
+	ProgressDrop
+	// ProgressDownsample stores a progress update only once the
+	// progress has moved by at least LogPolicy.ProgressStep.
+	ProgressDownsample
+)
+
+var progressLoggingNames = map[ProgressLogging]string{
+	ProgressKeep:       "keep",
+	ProgressDrop:       "drop",
+	ProgressDownsample: "downsample",
+}
+
+func (p ProgressLogging) String() string {
+	if name, ok := progressLoggingNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("ProgressLogging(%d)", int(p))
+}
+
+// ParseProgressLogging returns the progress logging with the given
+// name.
+func ParseProgressLogging(name string) (ProgressLogging, error) {
+	for p, n := range progressLoggingNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return ProgressKeep, fmt.Errorf("unknown progress logging %+q", name)
+}
+
+// defaultProgressStep is the default LogPolicy.ProgressStep.
+const defaultProgressStep = 0.01
+
+// LogPolicy decides how the output of makemkvcon is stored. The zero
+// value stores every line, uncompressed.
+type LogPolicy struct {
+	Progress ProgressLogging
+	// ProgressStep is the fraction of the total progress that must
+	// be made before another update is stored, when downsampling.
+	// It defaults to 1%.
+	ProgressStep float64
+	// Compress stores each log as a single compressed blob, rather
+	// than a row per line.
+	Compress bool
+}
+
+// progressFilter decides which progress updates of a single run are
+// stored.
+type progressFilter struct {
+	policy LogPolicy
+	last   *ProgressUpdate
+}
+
+// keep returns whether the line should be stored.
+func (f *progressFilter) keep(msg *StreamResult) bool {
+	update, ok := msg.Parsed.(*ProgressUpdate)
+	if !ok {
+		return true
+	}
+	switch f.policy.Progress {
+	case ProgressDrop:
+		return false
+	case ProgressDownsample:
+		step := f.policy.ProgressStep
+		if step <= 0 {
+			step = defaultProgressStep
+		}
+		last := f.last
+		// Always keep the first and last update, and the first
+		// of every new operation.
+		if last == nil || update.Total >= update.Max || update.Total < last.Total || update.Max != last.Max ||
+			float64(update.Total-last.Total) >= step*float64(update.Max) {
+			f.last = update
+			return true
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package makemkv
+
+import (
+	"path"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+)
+
+func progress(total, max int) *StreamResult {
+	return &StreamResult{
+		Type:   ProgressUpdateTag,
+		Parsed: &ProgressUpdate{Total: total, Max: max},
+	}
+}
+
+func TestProgressFilter(t *testing.T) {
+	updates := []*StreamResult{
+		progress(0, 1000),
+		progress(5, 1000),
+		progress(10, 1000),
+		progress(15, 1000),
+		progress(40, 1000),
+		progress(41, 1000),
+		// A new operation starts over.
+		progress(0, 1000),
+		progress(1000, 1000),
+	}
+	tests := map[ProgressLogging][]bool{
+		ProgressKeep:       {true, true, true, true, true, true, true, true},
+		ProgressDrop:       {false, false, false, false, false, false, false, false},
+		ProgressDownsample: {true, false, true, false, true, false, true, true},
+	}
+	for policy, want := range tests {
+		t.Run(policy.String(), func(t *testing.T) {
+			filter := &progressFilter{policy: LogPolicy{Progress: policy}}
+			for i, update := range updates {
+				if got := filter.keep(update); got != want[i] {
+					t.Errorf("update %d: got %v, want %v", i, got, want[i])
+				}
+			}
+			// Other lines are always kept.
+			if !filter.keep(&StreamResult{Type: MessageTag, Parsed: &Message{}}) {
+				t.Error("dropped a line that is not a progress update")
+			}
+		})
+	}
+}
+
+func TestParseProgressLogging(t *testing.T) {
+	for policy := range progressLoggingNames {
+		got, err := ParseProgressLogging(policy.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != policy {
+			t.Errorf("got %s, want %s", got, policy)
+		}
+	}
+	if _, err := ParseProgressLogging("sometimes"); err == nil {
+		t.Error("ParseProgressLogging unexpectedly succeeded")
+	}
+}
+
+func TestAnalyzeCompressedLog(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	mkv.LogPolicy = LogPolicy{Compress: true}
+	if _, err := mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	rawLog := db.MakeMkvLog{}
+	if err := d.Last(&rawLog).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rawLog.Compressed) == 0 {
+		t.Fatal("log was not stored compressed")
+	}
+	discInfo, err := discInfoFromLog(d, rawLog.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discInfo == nil || discInfo.VolumeName != "VOLUME_ID" {
+		t.Errorf("got %+v from the compressed log, want VOLUME_ID", discInfo)
+	}
+}
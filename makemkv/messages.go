@@ -0,0 +1,210 @@
+package makemkv
+
+import (
+	"strconv"
+	"strings"
+)
+
+// As noted in parse.go, makemkvcon does not publish an index of its
+// message codes. The catalog below was assembled from observed
+// robot-mode logs, and is therefore incomplete. Messages with codes
+// that are not in the catalog are classified purely by their Flags,
+// with a fallback on well-known phrases in the Format string.
+
+// MessageKind is the semantic meaning of a Message, independent of
+// its language or exact wording.
+type MessageKind int
+
+const (
+	// MessageUnknown is any message not (yet) in the catalog.
+	MessageUnknown MessageKind = iota
+	// MessageStarted is printed once makemkvcon starts up.
+	MessageStarted
+	// MessageReadError indicates the drive failed to read a
+	// sector. makemkvcon will usually retry, and may eventually
+	// skip the sector.
+	MessageReadError
+	// MessageHashFailure indicates that the content read from
+	// disc did not match its expected hash (e.g., AACS content
+	// hash), usually as a result of a bad read.
+	MessageHashFailure
+	// MessageKeyMissing indicates that the disc cannot be
+	// decrypted because the necessary keys are unavailable.
+	MessageKeyMissing
+	// MessageDiscOpenFailed indicates that makemkvcon could not
+	// open the disc at all.
+	MessageDiscOpenFailed
+	// MessageTitleSaveFailed indicates that a single title could
+	// not be saved.
+	MessageTitleSaveFailed
+	// MessageTitlesSaved reports how many titles were saved (and
+	// how many failed) at the end of a rip.
+	MessageTitlesSaved
+	// MessageCopyComplete is the final summary of a rip. Like
+	// MessageTitlesSaved, it includes how many titles were saved.
+	MessageCopyComplete
+)
+
+var messageKindNames = map[MessageKind]string{
+	MessageUnknown:         "unknown",
+	MessageStarted:         "started",
+	MessageReadError:       "read error",
+	MessageHashFailure:     "hash failure",
+	MessageKeyMissing:      "key missing",
+	MessageDiscOpenFailed:  "disc open failed",
+	MessageTitleSaveFailed: "title save failed",
+	MessageTitlesSaved:     "titles saved",
+	MessageCopyComplete:    "copy complete",
+}
+
+func (k MessageKind) String() string {
+	if name, ok := messageKindNames[k]; ok {
+		return name
+	}
+	return "MessageKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Severity is how much attention a Message deserves.
+type Severity int
+
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityDebug:   "debug",
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+type catalogEntry struct {
+	kind MessageKind
+	// severity is the minimum severity for this kind of message,
+	// regardless of what the Flags say.
+	severity Severity
+	// saved and failed are the indices into Params holding the
+	// number of titles saved and failed, or -1 if not present.
+	saved  int
+	failed int
+}
+
+var (
+	catalog = map[int]catalogEntry{
+		// "%1 started"
+		1005: {kind: MessageStarted, severity: SeverityInfo, saved: -1, failed: -1},
+		// "Error '%1' occurred while reading '%2' at offset '%3'"
+		2003: {kind: MessageReadError, severity: SeverityWarning, saved: -1, failed: -1},
+		// "Failed to save title %1 to file %2"
+		5003: {kind: MessageTitleSaveFailed, severity: SeverityError, saved: -1, failed: -1},
+		// "%1 titles saved, %2 failed"
+		5004: {kind: MessageTitlesSaved, severity: SeverityInfo, saved: 0, failed: 1},
+		// "%1 titles saved"
+		5005: {kind: MessageTitlesSaved, severity: SeverityInfo, saved: 0, failed: -1},
+		// "Failed to open disc"
+		5010: {kind: MessageDiscOpenFailed, severity: SeverityError, saved: -1, failed: -1},
+		// "Copy complete. %1 titles saved."
+		5036: {kind: MessageCopyComplete, severity: SeverityInfo, saved: 0, failed: -1},
+		// "Copy complete. %1 titles saved, %2 failed."
+		5037: {kind: MessageCopyComplete, severity: SeverityInfo, saved: 0, failed: 1},
+	}
+	// formatFallback classifies messages whose codes are not in
+	// the catalog, based on phrases in the (English) format
+	// string.
+	formatFallback = []struct {
+		phrase string
+		entry  catalogEntry
+	}{
+		{"occurred while reading", catalogEntry{kind: MessageReadError, severity: SeverityWarning, saved: -1, failed: -1}},
+		{"hash check failed", catalogEntry{kind: MessageHashFailure, severity: SeverityWarning, saved: -1, failed: -1}},
+		{"key not found", catalogEntry{kind: MessageKeyMissing, severity: SeverityError, saved: -1, failed: -1}},
+		{"no key", catalogEntry{kind: MessageKeyMissing, severity: SeverityError, saved: -1, failed: -1}},
+	}
+)
+
+// MessageClass is the result of classifying a Message.
+type MessageClass struct {
+	Kind     MessageKind
+	Severity Severity
+	// Saved and Failed are the number of titles saved and failed,
+	// only meaningful for MessageTitlesSaved and
+	// MessageCopyComplete.
+	Saved  int
+	Failed int
+}
+
+// severityOfFlags derives a severity from the message box bits.
+func severityOfFlags(flags MessageFlags) Severity {
+	switch flags & MessageBoxMask {
+	case MessageBoxError, MessageBoxYesNoErr:
+		return SeverityError
+	case MessageBoxWarning:
+		return SeverityWarning
+	}
+	if flags&(MessageDebug|MessageHidden) != 0 {
+		return SeverityDebug
+	}
+	return SeverityInfo
+}
+
+func param(msg *Message, index int) int {
+	if index < 0 || index >= len(msg.Params) {
+		return 0
+	}
+	value, err := strconv.Atoi(msg.Params[index])
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// Classify looks up the given message in the catalog. The severity is
+// the greater of what the Flags indicate and what the catalog expects
+// for that kind of message.
+func Classify(msg *Message) *MessageClass {
+	entry, ok := catalog[msg.Code]
+	if !ok {
+		format := strings.ToLower(msg.Format)
+		for _, fallback := range formatFallback {
+			if strings.Contains(format, fallback.phrase) {
+				entry = fallback.entry
+				ok = true
+				break
+			}
+		}
+	}
+	result := &MessageClass{
+		Kind:     MessageUnknown,
+		Severity: severityOfFlags(msg.Flags),
+	}
+	if !ok {
+		return result
+	}
+	result.Kind = entry.kind
+	result.Severity = max(result.Severity, entry.severity)
+	result.Saved = param(msg, entry.saved)
+	result.Failed = param(msg, entry.failed)
+	return result
+}
+
+// IsFailure reports whether this message indicates that the operation
+// as a whole did not succeed.
+func (c *MessageClass) IsFailure() bool {
+	switch c.Kind {
+	case MessageDiscOpenFailed, MessageTitleSaveFailed, MessageKeyMissing:
+		return true
+	case MessageTitlesSaved, MessageCopyComplete:
+		return c.Failed > 0 || c.Saved == 0
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package makemkv
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := map[string]struct {
+		input    *Message
+		expected MessageClass
+		failure  bool
+	}{
+		"unknown info": {
+			input:    &Message{Code: 9999},
+			expected: MessageClass{Kind: MessageUnknown, Severity: SeverityInfo},
+		},
+		"unknown error box": {
+			input:    &Message{Code: 9999, Flags: MessageBoxError},
+			expected: MessageClass{Kind: MessageUnknown, Severity: SeverityError},
+		},
+		"unknown debug": {
+			input:    &Message{Code: 9999, Flags: MessageDebug},
+			expected: MessageClass{Kind: MessageUnknown, Severity: SeverityDebug},
+		},
+		"read error": {
+			input: &Message{
+				Code:   2003,
+				Format: "Error '%1' occurred while reading '%2' at offset '%3'",
+				Params: []string{"Scsi error", "/BDMV/STREAM/00001.m2ts", "1048576"},
+			},
+			expected: MessageClass{Kind: MessageReadError, Severity: SeverityWarning},
+		},
+		"read error by format": {
+			input: &Message{
+				Code:   9999,
+				Format: "Error '%1' occurred while reading '%2' at offset '%3'",
+			},
+			expected: MessageClass{Kind: MessageReadError, Severity: SeverityWarning},
+		},
+		"titles saved": {
+			input:    &Message{Code: 5005, Flags: MessageEvent, Params: []string{"1"}},
+			expected: MessageClass{Kind: MessageTitlesSaved, Severity: SeverityInfo, Saved: 1},
+		},
+		"copy complete": {
+			input:    &Message{Code: 5036, Flags: MessageBoxOk, Params: []string{"1"}},
+			expected: MessageClass{Kind: MessageCopyComplete, Severity: SeverityInfo, Saved: 1},
+		},
+		"copy complete with failures": {
+			input:    &Message{Code: 5037, Flags: MessageBoxError, Params: []string{"1", "2"}},
+			expected: MessageClass{Kind: MessageCopyComplete, Severity: SeverityError, Saved: 1, Failed: 2},
+			failure:  true,
+		},
+		"nothing saved": {
+			input:    &Message{Code: 5005, Params: []string{"0"}},
+			expected: MessageClass{Kind: MessageTitlesSaved, Severity: SeverityInfo},
+			failure:  true,
+		},
+		"disc open failed": {
+			input:    &Message{Code: 5010},
+			expected: MessageClass{Kind: MessageDiscOpenFailed, Severity: SeverityError},
+			failure:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Classify(tt.input)
+			if *got != tt.expected {
+				t.Errorf("got %+v, want %+v", *got, tt.expected)
+			}
+			if got.IsFailure() != tt.failure {
+				t.Errorf("got failure %v, want %v", got.IsFailure(), tt.failure)
+			}
+		})
+	}
+}
@@ -3,21 +3,34 @@ package makemkv
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"sync"
 
+	"github.com/achernya/autorip/bdmv"
 	"github.com/achernya/autorip/db"
-	"github.com/achernya/autorip/discid"
+	"github.com/achernya/autorip/dvd"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type MakeMkv struct {
-	DB         *gorm.DB
+	DB *gorm.DB
+	// QualityPolicy decides what happens to rips that had read
+	// errors. The zero value treats any read error as degraded,
+	// but places degraded rips in the destination directory.
+	QualityPolicy QualityPolicy
+	// Ejector is used by Eject. It defaults to DeviceEjector.
+	Ejector Ejector
+	// MinSimilarity is how similar a new disc must be to one seen
+	// before for Analyze to report it. It defaults to 0.8.
+	MinSimilarity float64
+	// LogPolicy decides how the output of makemkvcon is stored.
+	LogPolicy  LogPolicy
 	makemkvcon string
 	session    *db.Session
 	dest       string
@@ -25,9 +38,11 @@ type MakeMkv struct {
 
 func New(d *gorm.DB, makemkvcon string, dest string) *MakeMkv {
 	return &MakeMkv{
-		DB:         d,
-		makemkvcon: makemkvcon,
-		dest:       dest,
+		DB:            d,
+		Ejector:       DeviceEjector{},
+		MinSimilarity: defaultMinSimilarity,
+		makemkvcon:    makemkvcon,
+		dest:          dest,
 	}
 }
 
@@ -63,9 +78,16 @@ func (m *MakeMkv) run(ctx context.Context, cb func(msg *StreamResult, eof bool),
 		return process.Wait()
 	}
 
+	logs := db.NewLogWriter(m.DB, rawLog.ID, m.LogPolicy.Compress)
+	filter := &progressFilter{policy: m.LogPolicy}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer func() {
+			if err := logs.Close(); err != nil {
+				log.Printf("Unable to store log %d: %v\n", rawLog.ID, err)
+			}
+		}()
 		stream := parser.Stream()
 		for {
 			select {
@@ -77,17 +99,11 @@ func (m *MakeMkv) run(ctx context.Context, cb func(msg *StreamResult, eof bool),
 				if !ok {
 					return
 				}
-				if len(msg.Raw) > 0 {
-					// Normally, with gorm, we'd want to do
-					//
-					// m.DB.Model(&rawLog).Association("Entry").Append(&db.MakeMkvLogEntry{Entry: msg.Raw})
-					//
-					// but this appears to be a giant read-modify-write of _every_ log entry.
-					// This is silly; we'll create it by hand.
-					m.DB.Create(&db.MakeMkvLogEntry{
-						MakeMkvLogID: rawLog.ID,
-						Entry:        msg.Raw,
-					})
+				if len(msg.Raw) == 0 || !filter.keep(msg) {
+					continue
+				}
+				if err := logs.WriteEntry(msg.Raw); err != nil {
+					log.Printf("Unable to store log %d: %v\n", rawLog.ID, err)
 				}
 			}
 		}
@@ -95,25 +111,6 @@ func (m *MakeMkv) run(ctx context.Context, cb func(msg *StreamResult, eof bool),
 	return wait, nil
 }
 
-func discInfoToFingerprint(discInfo *DiscInfo) ([]byte, error) {
-	disc := &discid.Disc{
-		Name:   discInfo.VolumeName,
-		Titles: make([]*discid.Title, 0),
-	}
-	for _, t := range discInfo.Titles {
-		title := &discid.Title{
-			Filename: t.SourceFileName,
-			Duration: t.Duration,
-		}
-		if size, err := strconv.ParseInt(t.DiskSizeBytes, 10, 64); err == nil {
-			title.Size = size
-		}
-		disc.Titles = append(disc.Titles, title)
-	}
-
-	return discid.Fingerprint(disc)
-}
-
 // ScanDrive will invoke `makemkvcon` to find all attached disc drives
 // and their state (e.g., is a disc inserted). Note that calling this
 // function may perturb any concurrent accesses other processes are
@@ -150,9 +147,21 @@ func (m *MakeMkv) ScanDrive() ([]*Drive, error) {
 }
 
 type Analysis struct {
+	// DriveIndex is -1 if the disc was analyzed without a drive,
+	// i.e., by AnalyzeBDMV or AnalyzeDVD.
 	DriveIndex int
 	New        bool
 	DiscInfo   *DiscInfo
+	// Fingerprint is the current-version fingerprint of the disc.
+	Fingerprint []byte
+	// Identification is the most recent identification of the
+	// disc, if it was seen before, either here or at a site whose
+	// identifications were imported. Identifications with low
+	// confidence are not reused.
+	Identification *db.Identification
+	// Similar is the most similar disc seen before, if this disc
+	// is new.
+	Similar *SimilarDisc
 }
 
 // Analyze finds the first drive with a disc inserted and analyzes the
@@ -210,20 +219,48 @@ func (m *MakeMkv) Analyze(drives []*Drive, cb func(msg *StreamResult, eof bool))
 		return nil, fmt.Errorf("internal error occurred, no disc info found")
 	}
 
-	fp, err := discInfoToFingerprint(discInfo)
+	return m.recordDisc(targetDrive, discInfo)
+}
+
+// AnalyzeBDMV analyzes the BDMV directory of a mounted Blu-ray disc
+// (or a copy of one) without makemkvcon. root may either be the BDMV
+// directory itself, or its parent.
+func (m *MakeMkv) AnalyzeBDMV(root string) (*Analysis, error) {
+	if err := m.sessionIfNeeded(); err != nil {
+		return nil, err
+	}
+	log.Printf("Analyzing %s\n", root)
+	disc, err := bdmv.Open(root)
 	if err != nil {
 		return nil, err
 	}
+	return m.recordDisc(-1, DiscInfoFromBDMV(disc))
+}
 
-	result := db.DiscFingerprint{}
-	insert := db.DiscFingerprint{
-		Fingerprint: fp,
-		Name:        discInfo.Name,
-		VolumeName:  discInfo.VolumeName,
+// AnalyzeDVD analyzes the VIDEO_TS directory of a mounted DVD (or a
+// copy of one) without makemkvcon. root may either be the VIDEO_TS
+// directory itself, or its parent.
+func (m *MakeMkv) AnalyzeDVD(root string) (*Analysis, error) {
+	if err := m.sessionIfNeeded(); err != nil {
+		return nil, err
+	}
+	log.Printf("Analyzing %s\n", root)
+	disc, err := dvd.Open(root)
+	if err != nil {
+		return nil, err
 	}
-	dbx := m.DB.Where("Fingerprint = ?", fp).Attrs(insert).FirstOrCreate(&result)
-	if dbx.Error != nil {
-		return nil, dbx.Error
+	discInfo, err := DiscInfoFromDVD(disc)
+	if err != nil {
+		return nil, err
+	}
+	return m.recordDisc(-1, discInfo)
+}
+
+// recordDisc fingerprints the disc and records it in the session.
+func (m *MakeMkv) recordDisc(driveIndex int, discInfo *DiscInfo) (*Analysis, error) {
+	result, isNew, err := findFingerprint(m.DB, discInfo)
+	if err != nil {
+		return nil, err
 	}
 	m.session.DiscFingerprintID = &result.ID
 	if err := m.DB.Save(m.session).Error; err != nil {
@@ -231,9 +268,10 @@ func (m *MakeMkv) Analyze(drives []*Drive, cb func(msg *StreamResult, eof bool))
 	}
 
 	analysis := &Analysis{
-		DriveIndex: targetDrive,
-		New:        dbx.RowsAffected != 0,
-		DiscInfo:   discInfo,
+		DriveIndex:  driveIndex,
+		New:         isNew,
+		DiscInfo:    discInfo,
+		Fingerprint: result.Fingerprint,
 	}
 
 	unique := "new"
@@ -241,31 +279,168 @@ func (m *MakeMkv) Analyze(drives []*Drive, cb func(msg *StreamResult, eof bool))
 		unique = "seen before"
 	}
 	log.Printf("Found disc %s (%s) = %s [%s]\n", result.VolumeName, result.Name, hex.EncodeToString(result.Fingerprint), unique)
+	if !analysis.New {
+		identification, err := latestIdentification(m.DB, result)
+		if err != nil {
+			return nil, err
+		}
+		analysis.Identification = identification
+	} else {
+		similar, err := findSimilar(m.DB, result, m.MinSimilarity)
+		if err != nil {
+			return nil, err
+		}
+		if similar != nil {
+			log.Printf("Disc %s %s\n", result.VolumeName, similar)
+		}
+		analysis.Similar = similar
+	}
 	return analysis, nil
 }
 
+// RecordPlan stores the identity chosen by the plan in the session,
+// along with the IMDb snapshot it was found in. Nothing is stored if
+// the disc could not be identified.
+func (m *MakeMkv) RecordPlan(plan *Plan) error {
+	if plan.Identity == nil {
+		return nil
+	}
+	if err := m.sessionIfNeeded(); err != nil {
+		return err
+	}
+	identification := &db.Identification{
+		TConst:        plan.Identity.GetTConst(),
+		TitleType:     plan.Identity.GetTitleType(),
+		PrimaryTitle:  plan.Identity.GetPrimaryTitle(),
+		StartYear:     plan.Identity.GetStartYear(),
+		LowConfidence: plan.LowConfidence,
+	}
+	if len(plan.Candidates) > 0 {
+		identification.Confidence = plan.Candidates[0].Confidence
+	}
+	edition, err := marshalEdition(plan.RipTitles)
+	if err != nil {
+		return err
+	}
+	identification.Edition = edition
+	for rank, candidate := range plan.Candidates {
+		factors, err := json.Marshal(candidate.Factors)
+		if err != nil {
+			return err
+		}
+		identification.Candidates = append(identification.Candidates, db.IdentificationCandidate{
+			Rank:         rank,
+			TConst:       candidate.Title.GetTConst(),
+			PrimaryTitle: candidate.Title.GetPrimaryTitle(),
+			Match:        candidate.Match.String(),
+			Confidence:   candidate.Confidence,
+			Factors:      factors,
+		})
+	}
+	if plan.Snapshot != nil {
+		metadata, err := json.Marshal(plan.Snapshot)
+		if err != nil {
+			return err
+		}
+		snapshot := db.ImdbSnapshot{}
+		insert := db.ImdbSnapshot{
+			Snapshot: plan.Snapshot.ID,
+			Metadata: metadata,
+		}
+		if err := m.DB.Where("Snapshot = ?", plan.Snapshot.ID).Attrs(insert).FirstOrCreate(&snapshot).Error; err != nil {
+			return err
+		}
+		identification.ImdbSnapshotID = &snapshot.ID
+	}
+	// Create rather than append to the association, so that the
+	// candidates are created along with it.
+	identification.SessionID = m.session.ID
+	return m.DB.Create(identification).Error
+}
+
 func (m *MakeMkv) Rip(drive *Drive, plan *Plan, cb func(msg *StreamResult, eof bool)) error {
 	if err := m.sessionIfNeeded(); err != nil {
 		return err
 	}
-	for _, title := range plan.RipTitles {
-		wait, err := m.run(context.Background(), cb, "--noscan", "mkv", fmt.Sprintf("disc:%d", drive.Index), fmt.Sprintf("%d", title.TitleIndex), m.dest)
+	args := []string{"--noscan"}
+	if !plan.StreamPolicy.IsDefault() {
+		profile, err := plan.StreamPolicy.WriteProfile("")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(profile) //nolint:errcheck
+		args = append(args, "--profile="+profile)
+	}
+	for _, title := range slices.Concat(plan.RipTitles, plan.Extras) {
+		// makemkvcon can exit successfully even if it failed
+		// to save the title, so the messages it printed are
+		// the source of truth.
+		var failure *Message
+		report := NewQualityReport(title.TitleIndex)
+		realCb := func(msg *StreamResult, eof bool) {
+			if cb != nil {
+				cb(msg, eof)
+			}
+			if eof {
+				return
+			}
+			switch msg := msg.Parsed.(type) {
+			case *Message:
+				if failure == nil && Classify(msg).IsFailure() {
+					failure = msg
+				}
+				report.Add(msg)
+			}
+		}
+		wait, err := m.run(context.Background(), realCb, slices.Concat(args, []string{"mkv", fmt.Sprintf("disc:%d", drive.Index), fmt.Sprintf("%d", title.TitleIndex), m.dest})...)
 		if err != nil {
 			return err
 		}
 		if err := wait(); err != nil {
 			return err
 		}
+		if failure != nil {
+			return fmt.Errorf("failed to rip title %d: %s", title.TitleIndex, failure.Message)
+		}
+		m.QualityPolicy.Apply(report)
+		log.Printf("Quality of %s\n", report)
+		if cb != nil {
+			cb(&StreamResult{Parsed: report}, false)
+		}
 
-		src := filepath.Join(m.dest, plan.DiscInfo.Titles[title.TitleIndex].OutputFileName)
+		filename := plan.DiscInfo.Titles[title.TitleIndex].OutputFileName
+		src := filepath.Join(m.dest, filename)
+		dstDir := m.dest
+		if report.Degraded && m.QualityPolicy.DegradedDir != "" {
+			dstDir = m.QualityPolicy.DegradedDir
+		}
 		if plan.Identity == nil {
 			log.Printf("Skipping renaming file since no identity was found")
-			continue
+		} else if plan.LowConfidence {
+			log.Printf("Skipping renaming file since the identity is not confident enough")
+		} else {
+			filename = plan.Filename(title)
 		}
-		dst := filepath.Join(m.dest, fmt.Sprintf("%s (%d).mkv", plan.Identity.GetPrimaryTitle(), plan.Identity.GetStartYear()))
-		log.Printf("Renaming %s to %s\n", src, dst)
-		err = os.Rename(src, dst)
-		if err != nil {
+		dst := filepath.Join(dstDir, filename)
+		if src != dst {
+			log.Printf("Renaming %s to %s\n", src, dst)
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(src, dst); err != nil {
+				return err
+			}
+		}
+		output := &db.RipOutput{
+			TitleIndex:   title.TitleIndex,
+			Path:         dst,
+			ReadErrors:   report.ReadErrors,
+			BadSectors:   report.BadSectors,
+			Retries:      report.Retries,
+			HashFailures: report.HashFailures,
+			Degraded:     report.Degraded,
+		}
+		if err := m.DB.Model(m.session).Association("Outputs").Append(output); err != nil {
 			return err
 		}
 	}
@@ -8,7 +8,9 @@ import (
 	"testing"
 
 	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/imdb"
 	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
 
 	pb "github.com/achernya/autorip/proto"
 )
@@ -130,6 +132,40 @@ func TestRip(t *testing.T) {
 			},
 			expected: []string{"title_t0.mkv", "title_t1.mkv"},
 		},
+		"extras": {
+			plan: &Plan{
+				Identity: pb.Title_builder{
+					PrimaryTitle: proto.String("Film"),
+					StartYear:    proto.Int32(2025),
+				}.Build(),
+				DiscInfo: &DiscInfo{
+					Titles: []TitleInfo{
+						{
+							GenericInfo: GenericInfo{
+								OutputFileName: "title_t0.mkv",
+							},
+						}, {
+							GenericInfo: GenericInfo{
+								OutputFileName: "title_t1.mkv",
+							},
+						},
+					},
+				},
+				RipTitles: []*Score{
+					{
+						TitleIndex: 0,
+					},
+				},
+				Extras: []*Score{
+					{
+						TitleIndex: 1,
+						Type:       ExtraType,
+					},
+				},
+				ExtrasPolicy: ExtrasPolicy{Layout: ExtrasFolder},
+			},
+			expected: []string{"Film (2025)/Film (2025).mkv", "Film (2025)/Extras/title_t1.mkv"},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -173,3 +209,202 @@ func TestRip(t *testing.T) {
 		})
 	}
 }
+
+func TestRipFailure(t *testing.T) {
+	t.Setenv("FAKEMKV_RIP_LOG", "ripfail.log")
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), dir)
+	plan := &Plan{
+		DiscInfo: &DiscInfo{
+			Titles: []TitleInfo{
+				{
+					GenericInfo: GenericInfo{
+						OutputFileName: "title_t0.mkv",
+					},
+				},
+			},
+		},
+		RipTitles: []*Score{
+			{
+				TitleIndex: 0,
+			},
+		},
+	}
+	if err := mkv.Rip(&Drive{Index: 0, State: 2}, plan, nil); err == nil {
+		t.Error("Rip unexpectedly succeeded even though makemkvcon reported a failure")
+	}
+}
+
+func TestRipDegraded(t *testing.T) {
+	t.Setenv("FAKEMKV_RIP_LOG", "ripdegraded.log")
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	degraded := t.TempDir()
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), dir)
+	mkv.QualityPolicy.DegradedDir = degraded
+	plan := &Plan{
+		Identity: pb.Title_builder{
+			PrimaryTitle: proto.String("Film"),
+			StartYear:    proto.Int32(2025),
+		}.Build(),
+		DiscInfo: &DiscInfo{
+			Titles: []TitleInfo{
+				{
+					GenericInfo: GenericInfo{
+						OutputFileName: "title_t0.mkv",
+					},
+				},
+			},
+		},
+		RipTitles: []*Score{
+			{
+				TitleIndex: 0,
+			},
+		},
+	}
+	f, err := os.Create(path.Join(dir, "title_t0.mkv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close() //nolint:errcheck
+
+	var report *QualityReport
+	cb := func(msg *StreamResult, eof bool) {
+		if eof {
+			return
+		}
+		if r, ok := msg.Parsed.(*QualityReport); ok {
+			report = r
+		}
+	}
+	if err := mkv.Rip(&Drive{Index: 0, State: 2}, plan, cb); err != nil {
+		t.Fatal(err)
+	}
+	if report == nil || !report.Degraded {
+		t.Errorf("got report %+v, want a degraded report", report)
+	}
+	if _, err := os.Stat(path.Join(degraded, "Film (2025).mkv")); err != nil {
+		t.Errorf("degraded rip was not placed in the degraded directory: %+v", err)
+	}
+	outputs := []db.RipOutput{}
+	if err := d.Find(&outputs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || !outputs[0].Degraded || outputs[0].ReadErrors != 2 {
+		t.Errorf("got outputs %+v, want a single degraded output with 2 read errors", outputs)
+	}
+}
+
+func TestRecordPlan(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	snapshot := &imdb.Snapshot{ID: "0123456789abcdef"}
+	for range 2 {
+		plan := &Plan{
+			Identity: pb.Title_builder{
+				TConst:       proto.String("tt0000001"),
+				PrimaryTitle: proto.String("Film"),
+				StartYear:    proto.Int32(2025),
+			}.Build(),
+			RipTitles: []*Score{{TitleIndex: 1, Playlist: "00800.mpls"}},
+			Snapshot:  snapshot,
+		}
+		if err := mkv.RecordPlan(plan); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing should be recorded for a disc that wasn't identified.
+	if err := mkv.RecordPlan(&Plan{}); err != nil {
+		t.Fatal(err)
+	}
+
+	identifications := []db.Identification{}
+	if err := d.Preload("ImdbSnapshot").Find(&identifications).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(identifications) != 2 {
+		t.Fatalf("got %d identifications, want 2", len(identifications))
+	}
+	for _, identification := range identifications {
+		if identification.TConst != "tt0000001" {
+			t.Errorf("got %s, want tt0000001", identification.TConst)
+		}
+		if identification.ImdbSnapshot == nil || identification.ImdbSnapshot.Snapshot != snapshot.ID {
+			t.Errorf("identification does not reference snapshot %s", snapshot.ID)
+		}
+		edition, err := parseEdition(identification.Edition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []EditionTitle{{TitleIndex: 1, Playlist: "00800.mpls"}}; !reflect.DeepEqual(edition, want) {
+			t.Errorf("got edition %+v, want %+v", edition, want)
+		}
+	}
+	var snapshots int64
+	if err := d.Model(&db.ImdbSnapshot{}).Count(&snapshots).Error; err != nil {
+		t.Fatal(err)
+	}
+	if snapshots != 1 {
+		t.Errorf("got %d snapshots, want 1", snapshots)
+	}
+}
+
+func TestRecordPlanCandidates(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	film := pb.Title_builder{
+		TConst:       proto.String("tt0000001"),
+		PrimaryTitle: proto.String("Film"),
+	}.Build()
+	remake := pb.Title_builder{
+		TConst:       proto.String("tt0000002"),
+		PrimaryTitle: proto.String("Film"),
+	}.Build()
+	plan := &Plan{
+		Identity: film,
+		Candidates: []*Candidate{
+			{Title: film, Confidence: 0.4, Factors: []Factor{{Name: "text", Value: 1, Weight: 1}}},
+			{Title: remake, Match: imdb.MatchFuzzy, Confidence: 0.3},
+		},
+		LowConfidence: true,
+	}
+	if err := mkv.RecordPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	identification := db.Identification{}
+	if err := d.Preload("Candidates", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("rank")
+	}).First(&identification).Error; err != nil {
+		t.Fatal(err)
+	}
+	if identification.Confidence != 0.4 || !identification.LowConfidence {
+		t.Errorf("got confidence %f (low: %t), want 0.4 (low: true)", identification.Confidence, identification.LowConfidence)
+	}
+	if len(identification.Candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(identification.Candidates))
+	}
+	if got := identification.Candidates[1]; got.TConst != "tt0000002" || got.Match != "fuzzy" {
+		t.Errorf("got %+v, want the fuzzy match tt0000002", got)
+	}
+	factors := []Factor{}
+	if err := json.Unmarshal(identification.Candidates[0].Factors, &factors); err != nil {
+		t.Fatal(err)
+	}
+	if len(factors) != 1 || factors[0].Name != "text" {
+		t.Errorf("got factors %+v, want the text factor", factors)
+	}
+}
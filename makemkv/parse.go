@@ -16,8 +16,8 @@ import (
 //
 // Frequently the concept of `code` shows up, which is a unique,
 // language-netrual identifier for the given message. To my knowledge
-// there is no index of these messages published anywhere, which
-// effectively makes these fields useless.
+// there is no index of these messages published anywhere, so
+// messages.go contains a partial catalog built from observed logs.
 
 const (
 	MessageTag         = "MSG"
@@ -112,6 +112,22 @@ const (
 	DriveUnmounting  = 257
 )
 
+var driveStateNames = map[DriveState]string{
+	DriveEmptyClosed: "empty (closed)",
+	DriveEmptyOpen:   "empty (open)",
+	DriveInserted:    "inserted",
+	DriveLoading:     "loading",
+	DriveNoDrive:     "no drive",
+	DriveUnmounting:  "unmounting",
+}
+
+func (s DriveState) String() string {
+	if name, ok := driveStateNames[s]; ok {
+		return name
+	}
+	return "DriveState(" + strconv.Itoa(int(s)) + ")"
+}
+
 type DiskFlags int
 
 const (
@@ -200,6 +216,28 @@ type DiscInfo struct {
 	Titles []TitleInfo
 }
 
+// TitleCount is emitted when makemkvcon reports how many titles it
+// found on the disc, before any of the titles are described.
+type TitleCount struct {
+	Count int
+}
+
+// TitleComplete is emitted once all of the information for a single
+// title, including all of its streams, has been read. Title is a copy
+// of the title as it will appear in the final DiscInfo.
+type TitleComplete struct {
+	Index int
+	Title *TitleInfo
+}
+
+// StreamAdded is emitted once all of the information for a single
+// stream within a title has been read.
+type StreamAdded struct {
+	TitleIndex  int
+	StreamIndex int
+	Stream      *StreamInfo
+}
+
 type StreamResult struct {
 	Raw    string
 	Type   string
@@ -211,6 +249,15 @@ type MakeMkvParser struct {
 	discInfo    *DiscInfo
 	infoSeen    bool
 	infoEmitted bool
+	// currTitle and currStream track which title and stream the
+	// INFO block is currently describing, so that TitleComplete
+	// and StreamAdded can be emitted as soon as makemkvcon moves
+	// on to the next one. Both are -1 if nothing is in progress.
+	currTitle  int
+	currStream int
+	// pending holds synthesized events that must be emitted
+	// before the record currently being parsed.
+	pending []*StreamResult
 }
 
 func ensureTitles(info *DiscInfo, titles int) {
@@ -266,6 +313,51 @@ func updateGenericInfo(info *GenericInfo, records []string) error {
 	return nil
 }
 
+// finishStream emits a StreamAdded for the stream currently being
+// described, if there is one.
+func (m *MakeMkvParser) finishStream() {
+	if m.currTitle < 0 || m.currStream < 0 {
+		return
+	}
+	stream := m.discInfo.Titles[m.currTitle].Streams[m.currStream]
+	m.pending = append(m.pending, &StreamResult{Parsed: &StreamAdded{
+		TitleIndex:  m.currTitle,
+		StreamIndex: m.currStream,
+		Stream:      &stream,
+	}})
+	m.currStream = -1
+}
+
+// finishTitle emits a StreamAdded for any stream in progress, followed
+// by a TitleComplete for the title currently being described, if
+// there is one.
+func (m *MakeMkvParser) finishTitle() {
+	m.finishStream()
+	if m.currTitle < 0 {
+		return
+	}
+	title := m.discInfo.Titles[m.currTitle]
+	m.pending = append(m.pending, &StreamResult{Parsed: &TitleComplete{
+		Index: m.currTitle,
+		Title: &title,
+	}})
+	m.currTitle = -1
+}
+
+// advance records that the INFO block is now describing the given
+// title and stream (or no stream, if stream is -1), finishing any
+// title or stream that came before.
+func (m *MakeMkvParser) advance(title int, stream int) {
+	if title != m.currTitle {
+		m.finishTitle()
+		m.currTitle = title
+	}
+	if stream >= 0 && stream != m.currStream {
+		m.finishStream()
+		m.currStream = stream
+	}
+}
+
 func parseMessage(records []string) (*Message, error) {
 	const columns = 5
 	if len(records) < columns {
@@ -425,6 +517,7 @@ func (m *MakeMkvParser) parseRecord() (*StreamResult, error) {
 			return nil, err
 		}
 		ensureStreams(m.discInfo, title, stream)
+		m.advance(title, stream)
 		err = updateGenericInfo(&m.discInfo.Titles[title].Streams[stream].GenericInfo, records[2:])
 		if err != nil {
 			return nil, err
@@ -436,6 +529,7 @@ func (m *MakeMkvParser) parseRecord() (*StreamResult, error) {
 			return nil, err
 		}
 		ensureTitles(m.discInfo, title)
+		m.advance(title, -1)
 		err = updateGenericInfo(&m.discInfo.Titles[title].GenericInfo, records[1:])
 		if err != nil {
 			return nil, err
@@ -447,13 +541,33 @@ func (m *MakeMkvParser) parseRecord() (*StreamResult, error) {
 			return nil, err
 		}
 	case TitleCountTag:
-		// We don't actually care about `TCOUNT`, so we can just ignore it.
+		if len(records) != 1 {
+			return nil, fmt.Errorf("unexpected number of columns for title count: got %+v, want 1", records)
+		}
+		count, err := strconv.Atoi(records[0])
+		if err != nil {
+			return nil, err
+		}
+		result.Parsed = &TitleCount{Count: count}
 	default:
 		return nil, fmt.Errorf("unknown message type: %+v", msgType)
 	}
 	return result, nil
 }
 
+// flush sends all pending synthesized events.
+func (m *MakeMkvParser) flush(out chan<- *StreamResult) {
+	for _, event := range m.pending {
+		out <- event
+	}
+	m.pending = nil
+}
+
+// Stream parses the robot-output line by line. Every line produces a
+// StreamResult with Raw set. In addition, the parser synthesizes
+// results without Raw: a StreamAdded and TitleComplete as each stream
+// and title in the INFO block is fully described, and the accumulated
+// DiscInfo once the INFO block ends.
 func (m *MakeMkvParser) Stream() <-chan *StreamResult {
 	out := make(chan *StreamResult)
 	go func() {
@@ -463,11 +577,16 @@ func (m *MakeMkvParser) Stream() <-chan *StreamResult {
 			if err != nil {
 				panic(fmt.Sprintf("Failed to parse %+v: %s", m.scanner.Text(), err.Error()))
 			}
-			if obj != nil {
-				out <- obj
+			endOfInfo := strings.HasSuffix(prevTag, InfoSuffix) && !strings.HasSuffix(obj.Type, InfoSuffix)
+			if endOfInfo {
+				// The last title is complete as soon as
+				// the INFO block ends.
+				m.finishTitle()
 			}
+			m.flush(out)
+			out <- obj
 			if prevTag != obj.Type {
-				if strings.HasSuffix(prevTag, InfoSuffix) && !strings.HasSuffix(obj.Type, InfoSuffix) {
+				if endOfInfo {
 					m.infoEmitted = true
 					out <- &StreamResult{Parsed: m.discInfo}
 				}
@@ -475,6 +594,8 @@ func (m *MakeMkvParser) Stream() <-chan *StreamResult {
 			}
 		}
 		if !m.infoEmitted && m.infoSeen {
+			m.finishTitle()
+			m.flush(out)
 			out <- &StreamResult{Parsed: m.discInfo}
 		}
 		close(out)
@@ -484,7 +605,9 @@ func (m *MakeMkvParser) Stream() <-chan *StreamResult {
 
 func NewParser(r io.Reader) *MakeMkvParser {
 	return &MakeMkvParser{
-		scanner:  bufio.NewScanner(r),
-		discInfo: &DiscInfo{},
+		scanner:    bufio.NewScanner(r),
+		discInfo:   &DiscInfo{},
+		currTitle:  -1,
+		currStream: -1,
 	}
 }
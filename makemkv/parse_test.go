@@ -2,6 +2,7 @@ package makemkv
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -34,6 +35,42 @@ SINFO:0,2,5,0,"S_HDMV/PGS"
 SINFO:0,2,6,0,"PGS"
 `
 
+var sampleTitle = TitleInfo{
+	GenericInfo: GenericInfo{
+		ChapterCount:   "4",
+		Duration:       "1:00:00",
+		DiskSize:       "10.0 GB",
+		DiskSizeBytes:  "10737418240",
+		SourceFileName: "00000.mpls",
+	},
+	Streams: []StreamInfo{
+		{
+			GenericInfo: GenericInfo{
+				Type:       "Video",
+				CodecId:    "V_MPEG2",
+				CodecShort: "Mpeg2",
+			},
+		},
+		{
+			GenericInfo: GenericInfo{
+				Type:       "Audio",
+				Name:       "Surround 5.1",
+				CodecId:    "A_DTS",
+				CodecShort: "DTS-HD MA",
+			},
+		},
+		{
+			GenericInfo: GenericInfo{
+				Type:       "Subtitles",
+				LangCode:   "eng",
+				LangName:   "English",
+				CodecId:    "S_HDMV/PGS",
+				CodecShort: "PGS",
+			},
+		},
+	},
+}
+
 func compareStreamResult(a, b *StreamResult) bool {
 	if a.Type != b.Type {
 		return false
@@ -94,49 +131,29 @@ func TestSuccessWithSampleLog(t *testing.T) {
 				DrivePath: "",
 			},
 		},
+		{
+			Type:   TitleCountTag,
+			Parsed: &TitleCount{Count: 1},
+		},
+		{
+			Parsed: &StreamAdded{TitleIndex: 0, StreamIndex: 0, Stream: &sampleTitle.Streams[0]},
+		},
+		{
+			Parsed: &StreamAdded{TitleIndex: 0, StreamIndex: 1, Stream: &sampleTitle.Streams[1]},
+		},
+		{
+			Parsed: &StreamAdded{TitleIndex: 0, StreamIndex: 2, Stream: &sampleTitle.Streams[2]},
+		},
+		{
+			Parsed: &TitleComplete{Index: 0, Title: &sampleTitle},
+		},
 		{
 			Parsed: &DiscInfo{
 				GenericInfo: GenericInfo{
 					Name:       "Volume Name",
 					VolumeName: "VOLUME_ID",
 				},
-				Titles: []TitleInfo{
-					{
-						GenericInfo: GenericInfo{
-							ChapterCount:   "4",
-							Duration:       "1:00:00",
-							DiskSize:       "10.0 GB",
-							DiskSizeBytes:  "10737418240",
-							SourceFileName: "00000.mpls",
-						},
-						Streams: []StreamInfo{
-							{
-								GenericInfo: GenericInfo{
-									Type:       "Video",
-									CodecId:    "V_MPEG2",
-									CodecShort: "Mpeg2",
-								},
-							},
-							{
-								GenericInfo: GenericInfo{
-									Type:       "Audio",
-									Name:       "Surround 5.1",
-									CodecId:    "A_DTS",
-									CodecShort: "DTS-HD MA",
-								},
-							},
-							{
-								GenericInfo: GenericInfo{
-									Type:       "Subtitles",
-									LangCode:   "eng",
-									LangName:   "English",
-									CodecId:    "S_HDMV/PGS",
-									CodecShort: "PGS",
-								},
-							},
-						},
-					},
-				},
+				Titles: []TitleInfo{sampleTitle},
 			},
 		},
 	}
@@ -172,3 +189,49 @@ func TestSuccessWithSampleLog(t *testing.T) {
 		}
 	}
 }
+
+func TestIncrementalEvents(t *testing.T) {
+	const log = `TCOUNT:2
+CINFO:2,0,"Volume Name"
+TINFO:0,9,0,"1:00:00"
+SINFO:0,0,1,6201,"Video"
+SINFO:0,1,1,6202,"Audio"
+TINFO:1,9,0,"0:30:00"
+SINFO:1,0,1,6201,"Video"
+MSG:5010,0,0,"Failed to open disc","Failed to open disc"
+`
+	// Each event is summarized as a string to make the ordering
+	// easy to compare.
+	want := []string{
+		"count 2",
+		"stream 0.0",
+		"stream 0.1",
+		"title 0",
+		"stream 1.0",
+		"title 1",
+		"msg 5010",
+		"disc 2",
+	}
+	got := []string{}
+	p := NewParser(strings.NewReader(log))
+	for result := range p.Stream() {
+		switch msg := result.Parsed.(type) {
+		case *TitleCount:
+			got = append(got, fmt.Sprintf("count %d", msg.Count))
+		case *StreamAdded:
+			got = append(got, fmt.Sprintf("stream %d.%d", msg.TitleIndex, msg.StreamIndex))
+		case *TitleComplete:
+			if msg.Title.Duration == "" {
+				t.Errorf("title %d completed without its duration", msg.Index)
+			}
+			got = append(got, fmt.Sprintf("title %d", msg.Index))
+		case *Message:
+			got = append(got, fmt.Sprintf("msg %d", msg.Code))
+		case *DiscInfo:
+			got = append(got, fmt.Sprintf("disc %d", len(msg.Titles)))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
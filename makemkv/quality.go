@@ -0,0 +1,86 @@
+package makemkv
+
+import (
+	"fmt"
+)
+
+// QualityReport summarizes how cleanly a single title was read from
+// the disc, based on the messages makemkvcon printed while ripping
+// it. makemkvcon exits successfully even if it had to skip unreadable
+// sectors, so this is the only indication that a rip may be damaged.
+type QualityReport struct {
+	TitleIndex int
+	// ReadErrors is the total number of read errors reported.
+	ReadErrors int
+	// BadSectors is the number of distinct offsets that produced
+	// a read error.
+	BadSectors int
+	// Retries is the number of read errors that were reported
+	// for an offset that had already failed once.
+	Retries int
+	// HashFailures is the number of content hash mismatches.
+	HashFailures int
+	// Degraded is set if the report exceeded the QualityPolicy.
+	Degraded bool
+
+	offsets map[string]bool
+}
+
+func NewQualityReport(titleIndex int) *QualityReport {
+	return &QualityReport{
+		TitleIndex: titleIndex,
+		offsets:    make(map[string]bool),
+	}
+}
+
+// Add accounts for a single message in the report. Messages that are
+// unrelated to read quality are ignored.
+func (r *QualityReport) Add(msg *Message) {
+	switch Classify(msg).Kind {
+	case MessageReadError:
+		r.ReadErrors++
+		// Params are (error, file, offset).
+		offset := fmt.Sprintf("%+v", msg.Params[min(1, len(msg.Params)):])
+		if r.offsets[offset] {
+			r.Retries++
+		} else {
+			r.offsets[offset] = true
+			r.BadSectors++
+		}
+	case MessageHashFailure:
+		r.HashFailures++
+	}
+}
+
+// Clean reports whether no read problems were observed at all.
+func (r *QualityReport) Clean() bool {
+	return r.ReadErrors == 0 && r.HashFailures == 0
+}
+
+func (r *QualityReport) String() string {
+	if r.Clean() {
+		return fmt.Sprintf("title %d: clean", r.TitleIndex)
+	}
+	degraded := ""
+	if r.Degraded {
+		degraded = " [degraded]"
+	}
+	return fmt.Sprintf("title %d: %d read errors (%d bad sectors, %d retries), %d hash failures%s",
+		r.TitleIndex, r.ReadErrors, r.BadSectors, r.Retries, r.HashFailures, degraded)
+}
+
+// QualityPolicy decides what to do with rips that had read problems.
+type QualityPolicy struct {
+	// MaxReadErrors is the number of read errors and hash
+	// failures tolerated before a rip is considered degraded.
+	MaxReadErrors int
+	// DegradedDir, if set, is where degraded rips are placed
+	// instead of the destination directory. If empty, degraded
+	// rips are still recorded as such, but placed normally.
+	DegradedDir string
+}
+
+// Apply marks the report as degraded if it exceeds the policy.
+func (p *QualityPolicy) Apply(r *QualityReport) {
+	r.Degraded = r.ReadErrors+r.HashFailures > p.MaxReadErrors
+}
@@ -0,0 +1,57 @@
+package makemkv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func readError(offset string) *Message {
+	return &Message{
+		Code:   2003,
+		Format: "Error '%1' occurred while reading '%2' at offset '%3'",
+		Params: []string{"Scsi error", "/BDMV/STREAM/00001.m2ts", offset},
+	}
+}
+
+func TestQualityReport(t *testing.T) {
+	tests := map[string]struct {
+		input    []*Message
+		policy   QualityPolicy
+		expected QualityReport
+	}{
+		"clean": {
+			input:    []*Message{{Code: 5005, Params: []string{"1"}}},
+			expected: QualityReport{},
+		},
+		"single read error": {
+			input:    []*Message{readError("1")},
+			expected: QualityReport{ReadErrors: 1, BadSectors: 1, Degraded: true},
+		},
+		"retried read error": {
+			input:    []*Message{readError("1"), readError("1"), readError("2")},
+			expected: QualityReport{ReadErrors: 3, BadSectors: 2, Retries: 1, Degraded: true},
+		},
+		"tolerated read errors": {
+			input:    []*Message{readError("1"), readError("2")},
+			policy:   QualityPolicy{MaxReadErrors: 2},
+			expected: QualityReport{ReadErrors: 2, BadSectors: 2},
+		},
+		"hash failure": {
+			input:    []*Message{{Code: 9999, Format: "Hash check failed for file %1 at offset %2"}},
+			expected: QualityReport{HashFailures: 1, Degraded: true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewQualityReport(0)
+			for _, msg := range tt.input {
+				r.Add(msg)
+			}
+			tt.policy.Apply(r)
+			r.offsets = nil
+			if !reflect.DeepEqual(*r, tt.expected) {
+				t.Errorf("got %+v, want %+v", *r, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package makemkv
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/achernya/autorip/db"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// exportFormat is the version of the format written by Export.
+const exportFormat = 1
+
+// SharedDisc is a disc fingerprint and what it was identified as, as
+// exchanged between sites by Export and Import.
+type SharedDisc struct {
+	Fingerprint []byte
+	// Version is the discid.Version of the fingerprint.
+	Version    int
+	Name       string
+	VolumeName string
+	Signature  datatypes.JSON `json:",omitempty"`
+
+	TConst       string
+	TitleType    string
+	PrimaryTitle string
+	StartYear    int32
+	Confidence   float64
+	Identified   time.Time
+	// Edition is the []EditionTitle that were ripped as the
+	// identity, if known.
+	Edition datatypes.JSON `json:",omitempty"`
+}
+
+func newSharedDisc(fp *db.DiscFingerprint, identification *db.Identification) SharedDisc {
+	return SharedDisc{
+		Fingerprint:  fp.Fingerprint,
+		Version:      fp.Version,
+		Name:         fp.Name,
+		VolumeName:   fp.VolumeName,
+		Signature:    fp.Signature,
+		TConst:       identification.TConst,
+		TitleType:    identification.TitleType,
+		PrimaryTitle: identification.PrimaryTitle,
+		StartYear:    identification.StartYear,
+		Confidence:   identification.Confidence,
+		Identified:   identification.CreatedAt.UTC(),
+		Edition:      identification.Edition,
+	}
+}
+
+// exported is the payload of an exported file.
+type exported struct {
+	Format   int
+	Exported time.Time
+	Discs    []SharedDisc
+}
+
+// signedExport is an exported file: the payload, and its signature by
+// the exporting site.
+type signedExport struct {
+	PublicKey ed25519.PublicKey
+	Signature []byte
+	Payload   []byte
+}
+
+// KeyID returns the printable form of a public key, as used to trust
+// it.
+func KeyID(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParseKeyID parses a public key printed by KeyID.
+func ParseKeyID(id string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("key %+q: %w", id, err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("key %+q is %d bytes, want %d", id, len(key), ed25519.PublicKeySize)
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey reads the PEM-encoded signing key of this site from
+// filename, generating it first if it does not exist.
+func LoadOrCreateKey(filename string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		b = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(filename, b, 0o600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s does not contain a PEM-encoded private key", filename)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s contains a %T, not an ed25519 key", filename, parsed)
+	}
+	return key, nil
+}
+
+// Export writes every identified disc to w, signed with key, and
+// returns how many discs were written. Only the most recent
+// identification of each disc is exported, and identifications that
+// were too low in confidence to rename rips are not.
+func Export(d *gorm.DB, key ed25519.PrivateKey, w io.Writer) (int, error) {
+	fingerprints := []db.DiscFingerprint{}
+	if err := d.Order("id").Find(&fingerprints).Error; err != nil {
+		return 0, err
+	}
+	payload := exported{
+		Format:   exportFormat,
+		Exported: time.Now().UTC(),
+		Discs:    make([]SharedDisc, 0),
+	}
+	for _, fp := range fingerprints {
+		identification, err := latestIdentification(d, &fp)
+		if err != nil {
+			return 0, err
+		}
+		if identification == nil || identification.LowConfidence {
+			continue
+		}
+		payload.Discs = append(payload.Discs, newSharedDisc(&fp, identification))
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	signed := signedExport{
+		PublicKey: key.Public().(ed25519.PublicKey),
+		Signature: ed25519.Sign(key, b),
+		Payload:   b,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(signed); err != nil {
+		return 0, err
+	}
+	return len(payload.Discs), nil
+}
+
+// ImportStatus is what Import did with a single disc.
+type ImportStatus int
+
+const (
+	// ImportCreated means the disc had not been identified here,
+	// and now has the imported identity.
+	ImportCreated ImportStatus = iota
+	// ImportUnchanged means the disc was already identified as the
+	// imported identity.
+	ImportUnchanged
+	// ImportConflict means the disc was identified as something
+	// else here, and that identification was kept.
+	ImportConflict
+	// ImportReplaced means the disc was identified as something
+	// else here, and the imported identity replaced it.
+	ImportReplaced
+)
+
+var importStatusNames = map[ImportStatus]string{
+	ImportCreated:   "created",
+	ImportUnchanged: "unchanged",
+	ImportConflict:  "conflict",
+	ImportReplaced:  "replaced",
+}
+
+func (s ImportStatus) String() string {
+	if name, ok := importStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ImportStatus(%d)", int(s))
+}
+
+// Errors returned by Import for exports it refuses. Any other error
+// is a failure to store the imported discs.
+var (
+	ErrMalformedExport = errors.New("malformed export")
+	ErrUntrustedKey    = errors.New("export is not signed by a trusted key")
+	ErrBadSignature    = errors.New("export is not correctly signed")
+)
+
+// Imported is a disc that was read by Import.
+type Imported struct {
+	Disc   SharedDisc
+	Status ImportStatus
+	// Local is the identification the disc already had here, if
+	// any.
+	Local *db.Identification
+}
+
+// Import merges a file written by Export into the database. The file
+// must be signed by one of the trusted keys. Discs that were already
+// identified as something else here are reported as conflicts, and
+// keep their identity, unless replace is set.
+func Import(d *gorm.DB, r io.Reader, trusted []ed25519.PublicKey, replace bool) ([]Imported, error) {
+	signed := signedExport{}
+	if err := json.NewDecoder(r).Decode(&signed); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedExport, err)
+	}
+	if len(signed.PublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: malformed public key", ErrMalformedExport)
+	}
+	if !slices.ContainsFunc(trusted, func(key ed25519.PublicKey) bool {
+		return bytes.Equal(key, signed.PublicKey)
+	}) {
+		return nil, fmt.Errorf("%w (signed by %s, which is not trusted)", ErrUntrustedKey, KeyID(signed.PublicKey))
+	}
+	if !ed25519.Verify(signed.PublicKey, signed.Payload, signed.Signature) {
+		return nil, fmt.Errorf("%w by %s", ErrBadSignature, KeyID(signed.PublicKey))
+	}
+	payload := exported{}
+	if err := json.Unmarshal(signed.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedExport, err)
+	}
+	if payload.Format != exportFormat {
+		return nil, fmt.Errorf("%w: unsupported format %d", ErrMalformedExport, payload.Format)
+	}
+
+	result := make([]Imported, 0, len(payload.Discs))
+	err := d.Transaction(func(tx *gorm.DB) error {
+		for _, disc := range payload.Discs {
+			imported, err := importDisc(tx, disc, signed.PublicKey, replace)
+			if err != nil {
+				return fmt.Errorf("disc %s: %w", disc.VolumeName, err)
+			}
+			result = append(result, *imported)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func importDisc(d *gorm.DB, disc SharedDisc, from ed25519.PublicKey, replace bool) (*Imported, error) {
+	if len(disc.Fingerprint) == 0 || disc.TConst == "" {
+		return nil, fmt.Errorf("%w: missing fingerprint or identity", ErrMalformedExport)
+	}
+	if _, err := parseEdition(disc.Edition); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedExport, err)
+	}
+	fp := &db.DiscFingerprint{}
+	err := d.Where("Fingerprint = ?", disc.Fingerprint).Attrs(db.DiscFingerprint{
+		Fingerprint: disc.Fingerprint,
+		Name:        disc.Name,
+		VolumeName:  disc.VolumeName,
+		Version:     disc.Version,
+		Signature:   disc.Signature,
+	}).FirstOrCreate(fp).Error
+	if err != nil {
+		return nil, err
+	}
+	local, err := latestIdentification(d, fp)
+	if err != nil {
+		return nil, err
+	}
+	result := &Imported{Disc: disc, Local: local, Status: ImportCreated}
+	if local != nil {
+		switch {
+		case local.TConst == disc.TConst:
+			result.Status = ImportUnchanged
+			return result, nil
+		case !replace:
+			result.Status = ImportConflict
+			return result, nil
+		}
+		result.Status = ImportReplaced
+	}
+	// The identification is recorded in a session of its own, as
+	// if the disc had been identified here.
+	session := &db.Session{
+		DiscFingerprintID: &fp.ID,
+		Identifications: []db.Identification{{
+			Model:        gorm.Model{CreatedAt: disc.Identified},
+			TConst:       disc.TConst,
+			TitleType:    disc.TitleType,
+			PrimaryTitle: disc.PrimaryTitle,
+			StartYear:    disc.StartYear,
+			Confidence:   disc.Confidence,
+			Edition:      disc.Edition,
+			ImportedFrom: from,
+		}},
+	}
+	if err := d.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
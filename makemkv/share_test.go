@@ -0,0 +1,206 @@
+package makemkv
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/discid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// recordIdentified records the disc of testdata/info.log as
+// identified as tconst.
+func recordIdentified(t *testing.T, d *gorm.DB, tconst string) {
+	fp, err := discInfoToFingerprint(testDiscInfo(t), discid.CurrentVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disc := &db.DiscFingerprint{}
+	if err := d.Where("Fingerprint = ?", fp).Attrs(db.DiscFingerprint{
+		Fingerprint: fp,
+		VolumeName:  "VOLUME_ID",
+		Version:     int(discid.CurrentVersion),
+	}).FirstOrCreate(disc).Error; err != nil {
+		t.Fatal(err)
+	}
+	session := &db.Session{
+		DiscFingerprintID: &disc.ID,
+		Identifications: []db.Identification{{
+			TConst:       tconst,
+			PrimaryTitle: "Film",
+			StartYear:    2025,
+			Confidence:   0.9,
+			Edition:      datatypes.JSON(`[{"TitleIndex":0,"Playlist":"00000.mpls"}]`),
+		}},
+	}
+	if err := d.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	filename := path.Join(t.TempDir(), "autorip.key")
+	created, err := LoadOrCreateKey(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateKey(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.Equal(loaded) {
+		t.Error("loaded a different key than was created")
+	}
+	id := KeyID(created.Public().(ed25519.PublicKey))
+	key, err := ParseKeyID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(created.Public()) {
+		t.Errorf("parsed %s as a different key", id)
+	}
+	if _, err := ParseKeyID("AAAA"); err == nil {
+		t.Error("ParseKeyID unexpectedly succeeded with a short key")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+
+	source := openTestDB(t)
+	recordIdentified(t, source, "tt0000001")
+	lowConfidence := &db.DiscFingerprint{Fingerprint: []byte("LOW"), VolumeName: "LOW"}
+	if err := source.Create(lowConfidence).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Create(&db.Session{
+		DiscFingerprintID: &lowConfidence.ID,
+		Identifications:   []db.Identification{{TConst: "tt0000002", LowConfidence: true}},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	export := &bytes.Buffer{}
+	count, err := Export(source, key, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("exported %d discs, want only the one identified with high confidence", count)
+	}
+
+	tests := map[string]struct {
+		local   string
+		replace bool
+		want    ImportStatus
+		tconst  string
+	}{
+		"new disc":         {"", false, ImportCreated, "tt0000001"},
+		"same identity":    {"tt0000001", false, ImportUnchanged, "tt0000001"},
+		"conflict":         {"tt0000003", false, ImportConflict, "tt0000003"},
+		"replace conflict": {"tt0000003", true, ImportReplaced, "tt0000001"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := openTestDB(t)
+			if tt.local != "" {
+				recordIdentified(t, d, tt.local)
+			}
+			imported, err := Import(d, bytes.NewReader(export.Bytes()), trusted, tt.replace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(imported) != 1 || imported[0].Status != tt.want {
+				t.Fatalf("got %+v, want one disc %s", imported, tt.want)
+			}
+			if tt.local != "" && imported[0].Local.TConst != tt.local {
+				t.Errorf("got local identity %s, want %s", imported[0].Local.TConst, tt.local)
+			}
+
+			// The disc is now known when it is analyzed.
+			mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+			analysis, err := mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analysis.Identification == nil || analysis.Identification.TConst != tt.tconst {
+				t.Fatalf("got identification %+v, want %s", analysis.Identification, tt.tconst)
+			}
+			want := tt.want == ImportCreated || tt.want == ImportReplaced
+			if got := len(analysis.Identification.ImportedFrom) > 0; got != want {
+				t.Errorf("got imported %v, want %v", got, want)
+			}
+			// So is the edition that was ripped.
+			edition, err := parseEdition(analysis.Identification.Edition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(edition) != 1 || edition[0].Playlist != "00000.mpls" {
+				t.Errorf("got edition %+v, want 00000.mpls", edition)
+			}
+		})
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := openTestDB(t)
+	recordIdentified(t, source, "tt0000001")
+	export := &bytes.Buffer{}
+	if _, err := Export(source, key, export); err != nil {
+		t.Fatal(err)
+	}
+
+	d := openTestDB(t)
+	if _, err := Import(d, bytes.NewReader(export.Bytes()), []ed25519.PublicKey{other.Public().(ed25519.PublicKey)}, false); !errors.Is(err, ErrUntrustedKey) {
+		t.Errorf("got error %v, want %v", err, ErrUntrustedKey)
+	}
+
+	signed := signedExport{}
+	if err := json.Unmarshal(export.Bytes(), &signed); err != nil {
+		t.Fatal(err)
+	}
+	signed.Payload = bytes.Replace(signed.Payload, []byte("tt0000001"), []byte("tt0000002"), 1)
+	tampered, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(d, bytes.NewReader(tampered), []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}, false); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("got error %v, want %v", err, ErrBadSignature)
+	}
+	if _, err := Import(d, strings.NewReader("not an export"), []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}, false); !errors.Is(err, ErrMalformedExport) {
+		t.Errorf("got error %v, want %v", err, ErrMalformedExport)
+	}
+	count := int64(0)
+	if err := d.Model(&db.Identification{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("got %d identifications after rejected imports, want 0", count)
+	}
+}
@@ -0,0 +1,108 @@
+package makemkv
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/discid"
+	"gorm.io/gorm"
+)
+
+// defaultMinSimilarity is the default MinSimilarity.
+const defaultMinSimilarity = 0.8
+
+// SimilarDisc is a disc that was seen before, and is similar to, but
+// does not have the same fingerprint as, the disc being analyzed.
+type SimilarDisc struct {
+	Fingerprint *db.DiscFingerprint
+	// Similarity is the discid.Signature similarity of the discs.
+	Similarity float64
+	// Identification is the most recent identification of the
+	// similar disc, if it was ever identified with enough
+	// confidence.
+	Identification *db.Identification
+}
+
+func (s *SimilarDisc) String() string {
+	result := fmt.Sprintf("looks like disc %s you analyzed on %s (%.0f%% similar)",
+		s.Fingerprint.VolumeName, s.Fingerprint.CreatedAt.Format("2006-01-02"), 100*s.Similarity)
+	if s.Identification != nil {
+		result += fmt.Sprintf(", identified as %s (%d) [%s]",
+			s.Identification.PrimaryTitle, s.Identification.StartYear, s.Identification.TConst)
+	}
+	return result
+}
+
+// findSimilar returns the disc most similar to fp, if any is at least
+// minSimilarity similar. Earlier versions of fp itself are not
+// considered.
+func findSimilar(d *gorm.DB, fp *db.DiscFingerprint, minSimilarity float64) (*SimilarDisc, error) {
+	signature := discid.Signature{}
+	if len(fp.Signature) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(fp.Signature, &signature); err != nil {
+		return nil, err
+	}
+	others := []db.DiscFingerprint{}
+	query := d.Select("id", "signature").Where("id <> ? AND signature IS NOT NULL", fp.ID)
+	if fp.PreviousID != nil {
+		query = query.Where("id <> ?", *fp.PreviousID)
+	}
+	if err := query.Find(&others).Error; err != nil {
+		return nil, err
+	}
+	var best *SimilarDisc
+	for _, other := range others {
+		otherSignature := discid.Signature{}
+		if err := json.Unmarshal(other.Signature, &otherSignature); err != nil {
+			return nil, err
+		}
+		similarity := signature.Similarity(otherSignature)
+		if similarity < minSimilarity || (best != nil && similarity <= best.Similarity) {
+			continue
+		}
+		best = &SimilarDisc{
+			Fingerprint: &db.DiscFingerprint{Model: gorm.Model{ID: other.ID}},
+			Similarity:  similarity,
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	if err := d.First(best.Fingerprint, best.Fingerprint.ID).Error; err != nil {
+		return nil, err
+	}
+	identification, err := latestIdentification(d, best.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	best.Identification = identification
+	return best, nil
+}
+
+// latestIdentification returns the most recent identification of the
+// disc, or nil if it was never identified. Identifications with low
+// confidence are only guesses, and are ignored.
+func latestIdentification(d *gorm.DB, fp *db.DiscFingerprint) (*db.Identification, error) {
+	// The disc may have been identified when it was recorded with
+	// an earlier fingerprint version.
+	ids := []uint{fp.ID}
+	if fp.PreviousID != nil {
+		ids = append(ids, *fp.PreviousID)
+	}
+	identifications := []db.Identification{}
+	err := d.Joins("JOIN sessions ON sessions.id = identifications.session_id").
+		Where("sessions.disc_fingerprint_id IN ?", ids).
+		Where("identifications.low_confidence = ?", false).
+		Order("identifications.id DESC").Limit(1).
+		Find(&identifications).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(identifications) == 0 {
+		return nil, nil
+	}
+	return &identifications[0], nil
+}
@@ -0,0 +1,266 @@
+package makemkv
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/achernya/autorip/db"
+	"github.com/achernya/autorip/discid"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+
+	pb "github.com/achernya/autorip/proto"
+)
+
+// recordSimilar records an identified disc with a different name, but
+// otherwise the same titles as testdata/info.log, and an unrelated
+// disc.
+func recordSimilar(t *testing.T, d *gorm.DB) *db.DiscFingerprint {
+	for _, disc := range []struct {
+		name      string
+		signature discid.Signature
+		tconst    string
+	}{
+		{"UNRELATED", discid.Signature{{Filename: "00000.mpls", Seconds: 5400}}, "tt0000002"},
+		{"OTHER_PRESSING", discid.Signature{{Filename: "00000.mpls", Seconds: 3600}}, "tt0000001"},
+	} {
+		signature, err := json.Marshal(disc.signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp := &db.DiscFingerprint{Fingerprint: []byte(disc.name), VolumeName: disc.name, Version: 2, Signature: signature}
+		if err := d.Create(fp).Error; err != nil {
+			t.Fatal(err)
+		}
+		session := &db.Session{
+			DiscFingerprintID: &fp.ID,
+			Identifications:   []db.Identification{{TConst: disc.tconst, PrimaryTitle: "Film", StartYear: 2025}},
+		}
+		if err := d.Create(session).Error; err != nil {
+			t.Fatal(err)
+		}
+		if disc.name == "OTHER_PRESSING" {
+			return fp
+		}
+	}
+	return nil
+}
+
+func TestAnalyzeFindsSimilar(t *testing.T) {
+	d, err := db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	similar := recordSimilar(t, d)
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	analysis, err := mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.New {
+		t.Error("analysis thinks a disc with a different fingerprint was seen before")
+	}
+	if analysis.Similar == nil {
+		t.Fatal("analysis found no similar disc")
+	}
+	if analysis.Similar.Fingerprint.ID != similar.ID || analysis.Similar.Similarity != 1 {
+		t.Errorf("got %+v, want %d with similarity 1", analysis.Similar, similar.ID)
+	}
+	if analysis.Similar.Identification == nil || analysis.Similar.Identification.TConst != "tt0000001" {
+		t.Errorf("got identification %+v, want tt0000001", analysis.Similar.Identification)
+	}
+	if s := analysis.Similar.String(); !strings.Contains(s, "OTHER_PRESSING") || !strings.Contains(s, "tt0000001") {
+		t.Errorf("got %s, want a mention of OTHER_PRESSING and tt0000001", s)
+	}
+
+	// Discs that are not similar enough are not reported.
+	d, err = db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordSimilar(t, d)
+	mkv = New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	mkv.MinSimilarity = 1.1
+	analysis, err = mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Similar != nil {
+		t.Errorf("got similar disc %+v, want none above the minimum similarity", analysis.Similar)
+	}
+
+	// Low confidence guesses at the identity of a similar disc are
+	// not reused.
+	d, err = db.OpenDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordSimilar(t, d)
+	if err := d.Model(&db.Identification{}).Where("1 = 1").Update("low_confidence", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	mkv = New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	analysis, err = mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Similar == nil {
+		t.Fatal("analysis found no similar disc")
+	}
+	if analysis.Similar.Identification != nil {
+		t.Errorf("got identification %+v, want none for a low confidence guess", analysis.Similar.Identification)
+	}
+}
+
+func TestMakePlanForPrefersSimilar(t *testing.T) {
+	disc := &DiscInfo{
+		GenericInfo: GenericInfo{
+			Name: "FILM",
+		},
+		Titles: []TitleInfo{
+			{
+				GenericInfo: GenericInfo{
+					Duration: "01:40:00",
+				},
+			},
+		},
+	}
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000001"),
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000002"),
+					PrimaryTitle:   proto.String("Film 2"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	similar := &SimilarDisc{
+		Fingerprint:    &db.DiscFingerprint{VolumeName: "OTHER_PRESSING"},
+		Similarity:     0.9,
+		Identification: &db.Identification{TConst: "tt0000002"},
+	}
+	i := NewIdentifier(index)
+	plan, err := i.MakePlanFor(&Analysis{DiscInfo: disc, Similar: similar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Identity.GetTConst() != "tt0000002" || plan.Candidates[0].Confidence != 0.9 {
+		t.Errorf("got identity %s with confidence %f, want tt0000002 with 0.9", plan.Identity.GetTConst(), plan.Candidates[0].Confidence)
+	}
+	for _, candidate := range plan.Candidates[1:] {
+		if candidate.Title.GetTConst() == "tt0000002" {
+			t.Error("the identity of the similar disc is a candidate twice")
+		}
+	}
+
+	// Identities that are no longer in the index are not reused.
+	similar.Identification.TConst = "tt0000003"
+	plan, err = i.MakePlanFor(&Analysis{DiscInfo: disc, Similar: similar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Identity.GetTConst() == "tt0000003" {
+		t.Error("reused an identity that is not in the index")
+	}
+
+	// Neither are identities that were only guessed.
+	similar.Identification = &db.Identification{TConst: "tt0000002", LowConfidence: true}
+	plan, err = i.MakePlanFor(&Analysis{DiscInfo: disc, Similar: similar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Candidates[0].Factors[0].Name == "similar" {
+		t.Error("reused a low confidence identity of a similar disc")
+	}
+	similar.Identification.LowConfidence = false
+
+	// The identity of the disc itself is preferred over that of a
+	// similar disc.
+	known := &db.Identification{TConst: "tt0000001"}
+	plan, err = i.MakePlanFor(&Analysis{DiscInfo: disc, Identification: known, Similar: similar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Identity.GetTConst() != "tt0000001" || plan.Candidates[0].Factors[0].Name != "known" {
+		t.Errorf("got identity %s from %s, want the known tt0000001", plan.Identity.GetTConst(), plan.Candidates[0].Factors[0].Name)
+	}
+}
+
+func TestMakePlanForIgnoresLowConfidence(t *testing.T) {
+	d := openTestDB(t)
+	recordIdentified(t, d, "tt0000001")
+	if err := d.Model(&db.Identification{}).Where("t_const = ?", "tt0000001").Update("low_confidence", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	mkv := New(d, path.Join("testdata", "fakemkv.sh"), ".")
+	analysis, err := mkv.Analyze([]*Drive{{Index: 0, State: DriveInserted}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.New {
+		t.Error("analysis thinks a disc that was seen before is new")
+	}
+	if analysis.Identification != nil {
+		t.Errorf("got identification %+v, want none for a low confidence guess", analysis.Identification)
+	}
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000001"),
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(60),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	i := NewIdentifier(index)
+	i.MinConfidence = 1
+	plan, err := i.MakePlanFor(analysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Known != nil || !plan.LowConfidence {
+		t.Errorf("got known %+v and LowConfidence %t, want a low confidence plan", plan.Known, plan.LowConfidence)
+	}
+}
+
+func TestMakePlanForKnownDiscWithoutTitles(t *testing.T) {
+	index := &fakeIndex{
+		results: []*pb.Result{
+			pb.Result_builder{
+				Entry: pb.Title_builder{
+					TConst:         proto.String("tt0000001"),
+					PrimaryTitle:   proto.String("Film"),
+					TitleType:      proto.String("movie"),
+					RuntimeMinutes: proto.Int32(100),
+				}.Build(),
+			}.Build(),
+		},
+	}
+	i := NewIdentifier(index)
+	plan, err := i.MakePlanFor(&Analysis{
+		DiscInfo:       &DiscInfo{},
+		Identification: &db.Identification{TConst: "tt0000001"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Identity.GetTConst() != "tt0000001" || len(plan.RipTitles) != 0 {
+		t.Errorf("got identity %s with %d titles to rip, want tt0000001 with none", plan.Identity.GetTConst(), len(plan.RipTitles))
+	}
+}
@@ -0,0 +1,80 @@
+package makemkv
+
+import (
+	"strings"
+
+	"github.com/achernya/autorip/imdb"
+)
+
+// Disc names are a poor approximation of the title: they are limited
+// to 32 characters (and therefore often truncated), frequently
+// abbreviated, and sometimes misspelled. Similarity accounts for all
+// three when comparing a disc name to a candidate title.
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// wordSimilarity is how well a single word of the disc name is
+// explained by the words of the title, between 0 and 1.
+func wordSimilarity(word string, title []string) float64 {
+	best := 0.0
+	for start, candidate := range title {
+		if word == candidate {
+			return 1
+		}
+		// Truncated, e.g., "fellows" for "fellowship".
+		if len(word) >= 3 && strings.HasPrefix(candidate, word) {
+			best = max(best, 0.9)
+		}
+		// Abbreviated, e.g., "lotr" for "lord of the rings".
+		if len(word) >= 2 && start+len(word) <= len(title) {
+			acronym := strings.Builder{}
+			for _, w := range title[start : start+len(word)] {
+				acronym.WriteRune([]rune(w)[0])
+			}
+			if acronym.String() == word {
+				best = max(best, 0.9)
+			}
+		}
+		// Misspelled.
+		a, b := []rune(word), []rune(candidate)
+		distance := levenshtein(a, b)
+		best = max(best, 1-float64(distance)/float64(max(len(a), len(b))))
+	}
+	return best
+}
+
+// Similarity is how well the disc name is explained by the title,
+// between 0 (not at all) and 1 (every word of the disc name appears
+// in the title). Words in the title that are not in the disc name are
+// not penalized, since the disc name may have been truncated.
+func Similarity(name string, title string) float64 {
+	nameWords := imdb.Words(name)
+	titleWords := imdb.Words(title)
+	if len(nameWords) == 0 || len(titleWords) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, word := range nameWords {
+		sum += wordSimilarity(word, titleWords)
+	}
+	return sum / float64(len(nameWords))
+}
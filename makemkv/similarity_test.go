@@ -0,0 +1,61 @@
+package makemkv
+
+import (
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := map[string]struct {
+		name  string
+		title string
+		want  float64
+	}{
+		"identical": {
+			name:  "THE_MATRIX",
+			title: "The Matrix",
+			want:  1,
+		},
+		"truncated": {
+			name:  "THE_LORD_OF_THE_RINGS_THE_FELLOWS",
+			title: "The Lord of the Rings: The Fellowship of the Ring",
+			want:  (6 + 0.9) / 7,
+		},
+		"abbreviated": {
+			name:  "LOTR_FOTR",
+			title: "The Lord of the Rings: The Fellowship of the Ring",
+			want:  0.9,
+		},
+		"misspelled": {
+			name:  "THE_MATIRX",
+			title: "The Matrix",
+			want:  (1 + 4.0/6) / 2,
+		},
+		"unrelated": {
+			name:  "ZZZ",
+			title: "The Matrix",
+			want:  0,
+		},
+		"empty": {
+			name:  "",
+			title: "The Matrix",
+			want:  0,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Similarity(tt.name, tt.title)
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("got %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarityPrefersBetterMatch(t *testing.T) {
+	name := "LOTR_FOTR"
+	fellowship := Similarity(name, "The Lord of the Rings: The Fellowship of the Ring")
+	towers := Similarity(name, "The Lord of the Rings: The Two Towers")
+	if fellowship <= towers {
+		t.Errorf("got %f <= %f, want the Fellowship of the Ring to be more similar", fellowship, towers)
+	}
+}
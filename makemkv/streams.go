@@ -0,0 +1,234 @@
+package makemkv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// makemkvcon decides which streams of a title to save using a
+// selection string, which is a comma-separated list of rules of the
+// form `action:condition`, applied in order. For example,
+// `-sel:all,+sel:(eng|nolang)` only keeps English streams, and
+// streams without a language. The selection string can be set in a
+// profile, which is passed to makemkvcon with --profile.
+//
+// StreamPolicy generates such a selection string, but also evaluates
+// the same rules itself, so that the plan shows exactly which streams
+// will be kept.
+
+// StreamFlags are the bits of the StreamFlags attribute of a stream,
+// as defined in makemkv's apdefs.h.
+const (
+	StreamDirectorsComments          = 1 << 0
+	StreamAlternateDirectorsComments = 1 << 1
+	StreamForVisuallyImpaired        = 1 << 2
+	StreamCoreAudio                  = 1 << 8
+	StreamSecondaryAudio             = 1 << 9
+	StreamHasCoreAudio               = 1 << 10
+	StreamDerivedStream              = 1 << 11
+	StreamForcedSubtitles            = 1 << 12
+)
+
+var (
+	// losslessCodecs are prefixes of the CodecId or CodecShort of
+	// lossless audio streams.
+	losslessCodecs = []string{"A_TRUEHD", "A_MLP", "A_PCM", "A_FLAC", "TrueHD", "LPCM", "FLAC", "DTS-HD MA"}
+)
+
+// StreamPolicy selects which streams of each title are saved. The
+// zero value keeps makemkvcon's own default selection. Any other
+// policy replaces that selection, and starts from every stream rather
+// than from makemkvcon's default: that default depends on the
+// preferred languages configured in makemkv, so the plan could not
+// show which streams it keeps. For example, LosslessOnly on its own
+// keeps audio in every language, not just the preferred ones.
+type StreamPolicy struct {
+	// Languages are the ISO 639-2 codes (e.g., "eng") of the
+	// audio and subtitle streams to keep. Streams without a
+	// language are always kept. If empty, streams in every
+	// language are kept.
+	Languages []string
+	// LosslessOnly drops lossy audio that duplicates a lossless
+	// stream, such as the core of a DTS-HD MA or TrueHD stream.
+	LosslessOnly bool
+	// DropCommentary drops director's (and other) commentary.
+	DropCommentary bool
+	// KeepForced keeps forced subtitles, even if they are not in
+	// one of the Languages.
+	KeepForced bool
+}
+
+// IsDefault reports whether the policy leaves the selection to
+// makemkvcon.
+func (p *StreamPolicy) IsDefault() bool {
+	return len(p.Languages) == 0 && !p.LosslessOnly && !p.DropCommentary && !p.KeepForced
+}
+
+func (p StreamPolicy) String() string {
+	if p.IsDefault() {
+		return "makemkvcon default"
+	}
+	return p.SelectionString()
+}
+
+// SelectionString returns the makemkvcon selection rules implementing
+// the policy, starting from every stream.
+func (p *StreamPolicy) SelectionString() string {
+	rules := []string{"+sel:all"}
+	if len(p.Languages) > 0 {
+		languages := strings.Join(append(slices.Clone(p.Languages), "nolang"), "|")
+		rules = append(rules, "-sel:(audio|subtitle)", fmt.Sprintf("+sel:((audio|subtitle)&(%s))", languages))
+	}
+	if p.LosslessOnly {
+		// Commentary is never a duplicate of the main audio.
+		rules = append(rules, "-sel:(core|(havelossless&!special))")
+	}
+	if p.DropCommentary {
+		rules = append(rules, "-sel:special")
+	}
+	if p.KeepForced {
+		rules = append(rules, "+sel:(subtitle&forced)")
+	}
+	return strings.Join(rules, ",")
+}
+
+// StreamDecision is whether a single stream will be saved, and why.
+type StreamDecision struct {
+	StreamIndex int
+	Type        string
+	LangCode    string
+	Codec       string
+	Keep        bool
+	Reason      string
+}
+
+func (d StreamDecision) String() string {
+	action := "keep"
+	if !d.Keep {
+		action = "drop"
+	}
+	lang := d.LangCode
+	if lang == "" {
+		lang = "-"
+	}
+	return fmt.Sprintf("%s stream %d (%s, %s, %s): %s", action, d.StreamIndex, d.Type, lang, d.Codec, d.Reason)
+}
+
+func streamFlags(si *StreamInfo) int {
+	flags, err := strconv.Atoi(si.StreamFlags)
+	if err != nil {
+		return 0
+	}
+	return flags
+}
+
+func isLossless(si *StreamInfo) bool {
+	return slices.ContainsFunc(losslessCodecs, func(codec string) bool {
+		return strings.HasPrefix(si.CodecId, codec) || strings.HasPrefix(si.CodecShort, codec)
+	})
+}
+
+// Select evaluates the policy against every stream of the title, in
+// the same order as the rules of the selection string.
+func (p *StreamPolicy) Select(ti *TitleInfo) []StreamDecision {
+	result := make([]StreamDecision, 0, len(ti.Streams))
+	for index := range ti.Streams {
+		si := &ti.Streams[index]
+		flags := streamFlags(si)
+		decision := StreamDecision{
+			StreamIndex: index,
+			Type:        si.Type,
+			LangCode:    si.LangCode,
+			Codec:       si.CodecShort,
+			Keep:        true,
+			Reason:      "default",
+		}
+		audioOrSubtitle := si.Type == "Audio" || si.Type == "Subtitles"
+		if len(p.Languages) > 0 && audioOrSubtitle && si.LangCode != "" && !slices.Contains(p.Languages, si.LangCode) {
+			decision.Keep = false
+			decision.Reason = "not a preferred language"
+		}
+		if p.LosslessOnly && si.Type == "Audio" && !isLossless(si) {
+			if flags&StreamCoreAudio != 0 {
+				decision.Keep = false
+				decision.Reason = "core of a lossless stream"
+			} else if flags&(StreamDirectorsComments|StreamAlternateDirectorsComments) == 0 && p.hasLossless(ti, si) {
+				decision.Keep = false
+				decision.Reason = "lossless version available"
+			}
+		}
+		if p.DropCommentary && flags&(StreamDirectorsComments|StreamAlternateDirectorsComments) != 0 {
+			decision.Keep = false
+			decision.Reason = "commentary"
+		}
+		if p.KeepForced && si.Type == "Subtitles" && flags&StreamForcedSubtitles != 0 {
+			decision.Keep = true
+			decision.Reason = "forced subtitles"
+		}
+		if decision.Keep && decision.Reason == "default" && !p.IsDefault() {
+			decision.Reason = "matches policy"
+		}
+		result = append(result, decision)
+	}
+	return result
+}
+
+// hasLossless reports whether the title has a lossless audio stream
+// in the same language as the given one.
+func (p *StreamPolicy) hasLossless(ti *TitleInfo, si *StreamInfo) bool {
+	return slices.ContainsFunc(ti.Streams, func(other StreamInfo) bool {
+		return other.Type == "Audio" && other.LangCode == si.LangCode && isLossless(&other)
+	})
+}
+
+// profile is a makemkvcon profile that only overrides the default
+// selection.
+type profile struct {
+	XMLName         xml.Name `xml:"profile"`
+	Name            profileName
+	ProfileSettings struct {
+		Selection string `xml:"app_DefaultSelectionString,attr"`
+	} `xml:"profileSettings"`
+	TrackSettings struct {
+		Input  string `xml:"input,attr"`
+		Output struct {
+			Settings         string `xml:"outputSettingsName,attr"`
+			DefaultSelection string `xml:"defaultSelection,attr"`
+		} `xml:"output"`
+	} `xml:"trackSettings"`
+}
+
+type profileName struct {
+	XMLName xml.Name `xml:"name"`
+	Lang    string   `xml:"lang,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+// WriteProfile writes a makemkvcon profile implementing the policy
+// into dir, and returns its path.
+func (p *StreamPolicy) WriteProfile(dir string) (string, error) {
+	prof := profile{
+		Name: profileName{Lang: "mogz", Value: "autorip"},
+	}
+	prof.ProfileSettings.Selection = p.SelectionString()
+	prof.TrackSettings.Input = "default"
+	prof.TrackSettings.Output.Settings = "copy"
+	prof.TrackSettings.Output.DefaultSelection = "$app_DefaultSelectionString"
+	b, err := xml.MarshalIndent(prof, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "autorip-*.mmcp.xml")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(xml.Header + string(b) + "\n"); err != nil {
+		f.Close() //nolint:errcheck
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,129 @@
+package makemkv
+
+import (
+	"encoding/xml"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func newStream(streamType string, lang string, codec string, flags int) StreamInfo {
+	return StreamInfo{
+		GenericInfo: GenericInfo{
+			Type:        streamType,
+			LangCode:    lang,
+			CodecShort:  codec,
+			StreamFlags: strconv.Itoa(flags),
+		},
+	}
+}
+
+func TestSelectionString(t *testing.T) {
+	tests := map[string]struct {
+		policy StreamPolicy
+		want   string
+	}{
+		"default": {
+			policy: StreamPolicy{},
+			want:   "+sel:all",
+		},
+		// Streams in every language are kept, not just
+		// makemkvcon's preferred ones.
+		"lossless only": {
+			policy: StreamPolicy{LosslessOnly: true},
+			want:   "+sel:all,-sel:(core|(havelossless&!special))",
+		},
+		"everything": {
+			policy: StreamPolicy{
+				Languages:      []string{"eng", "fra"},
+				LosslessOnly:   true,
+				DropCommentary: true,
+				KeepForced:     true,
+			},
+			want: "+sel:all,-sel:(audio|subtitle),+sel:((audio|subtitle)&(eng|fra|nolang)),-sel:(core|(havelossless&!special)),-sel:special,+sel:(subtitle&forced)",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.policy.SelectionString(); got != tt.want {
+				t.Errorf("got %+q, want %+q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	title := &TitleInfo{
+		Streams: []StreamInfo{
+			newStream("Video", "", "Mpeg4", 0),
+			newStream("Audio", "eng", "TrueHD", StreamHasCoreAudio),
+			newStream("Audio", "eng", "AC3", StreamCoreAudio),
+			newStream("Audio", "eng", "AC3", 0),
+			newStream("Audio", "eng", "AC3", StreamDirectorsComments),
+			newStream("Audio", "deu", "DTS", 0),
+			newStream("Subtitles", "eng", "PGS", 0),
+			newStream("Subtitles", "deu", "PGS", 0),
+			newStream("Subtitles", "deu", "PGS", StreamForcedSubtitles),
+		},
+	}
+	tests := map[string]struct {
+		policy StreamPolicy
+		want   []bool
+	}{
+		"default": {
+			policy: StreamPolicy{},
+			want:   []bool{true, true, true, true, true, true, true, true, true},
+		},
+		"languages": {
+			policy: StreamPolicy{Languages: []string{"eng"}},
+			want:   []bool{true, true, true, true, true, false, true, false, false},
+		},
+		"lossless only": {
+			policy: StreamPolicy{LosslessOnly: true},
+			want:   []bool{true, true, false, false, true, true, true, true, true},
+		},
+		"everything": {
+			policy: StreamPolicy{
+				Languages:      []string{"eng"},
+				LosslessOnly:   true,
+				DropCommentary: true,
+				KeepForced:     true,
+			},
+			want: []bool{true, true, false, false, false, false, true, false, true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := []bool{}
+			for _, decision := range tt.policy.Select(title) {
+				got = append(got, decision.Keep)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteProfile(t *testing.T) {
+	policy := &StreamPolicy{Languages: []string{"eng"}}
+	filename, err := policy.WriteProfile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := profile{}
+	if err := xml.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ProfileSettings.Selection != policy.SelectionString() {
+		t.Errorf("got selection %+q, want %+q", got.ProfileSettings.Selection, policy.SelectionString())
+	}
+	if got.TrackSettings.Output.DefaultSelection != "$app_DefaultSelectionString" {
+		t.Errorf("got output selection %+q, want it to use the profile's", got.TrackSettings.Output.DefaultSelection)
+	}
+}
@@ -18,7 +18,7 @@ while [[ "$#" -gt 0 ]]; do
 	    shift
 	    ;;
 	mkv)
-	    TARGET=rip.log
+	    TARGET=${FAKEMKV_RIP_LOG:-rip.log}
 	    shift
 	    ;;
 	*)
@@ -0,0 +1,142 @@
+package makemkv
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Writer serializes parsed records back into makemkvcon's robot-mode
+// format, such that MakeMkvParser will parse them back into the same
+// values. This is useful for generating synthetic discs for tests, or
+// for rewriting real logs (e.g., to remove identifying information)
+// before sharing them.
+//
+// Since the parser discards the message codes of CINFO, TINFO, and
+// SINFO records, the writer always emits 0 for them.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// quote produces a robot-mode string. makemkvcon escapes `"` as `\"`
+// rather than `""`, so do the same here.
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func (w *Writer) writeLine(tag string, columns ...string) error {
+	_, err := fmt.Fprintf(w.w, "%s:%s\n", tag, strings.Join(columns, ","))
+	return err
+}
+
+func (w *Writer) WriteMessage(msg *Message) error {
+	columns := []string{
+		strconv.Itoa(msg.Code),
+		strconv.Itoa(int(msg.Flags)),
+		strconv.Itoa(msg.Count),
+		quote(msg.Message),
+		quote(msg.Format),
+	}
+	for _, param := range msg.Params {
+		columns = append(columns, quote(param))
+	}
+	return w.writeLine(MessageTag, columns...)
+}
+
+func (w *Writer) WriteProgressTitle(progress *ProgressTitle) error {
+	tag := ProgressTitleTag
+	if progress.Type == ProgressCurrent {
+		tag = ProgressCurrentTag
+	}
+	return w.writeLine(tag, strconv.Itoa(progress.Code), strconv.Itoa(progress.Id), quote(progress.Name))
+}
+
+func (w *Writer) WriteProgressUpdate(progress *ProgressUpdate) error {
+	return w.writeLine(ProgressUpdateTag, strconv.Itoa(progress.Current), strconv.Itoa(progress.Total), strconv.Itoa(progress.Max))
+}
+
+func (w *Writer) WriteDrive(drive *Drive) error {
+	return w.writeLine(DriveTag,
+		strconv.Itoa(drive.Index),
+		strconv.Itoa(int(drive.State)),
+		strconv.Itoa(drive.Unknown),
+		strconv.Itoa(int(drive.Flags)),
+		quote(drive.DriveName),
+		quote(drive.DiscName),
+		quote(drive.DrivePath),
+	)
+}
+
+// writeGenericInfo writes one record for each non-empty field of
+// info, in attribute id order. prefix contains any columns that
+// precede the attribute id (e.g., the title index).
+func (w *Writer) writeGenericInfo(tag string, info *GenericInfo, prefix ...string) error {
+	v := reflect.ValueOf(info).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		id := v.Type().Field(i).Tag.Get(tagName)
+		if id == "" || id == "-" {
+			continue
+		}
+		value := v.Field(i).String()
+		if value == "" {
+			continue
+		}
+		columns := append(append([]string{}, prefix...), id, "0", quote(value))
+		if err := w.writeLine(tag, columns...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDiscInfo writes an entire INFO block: the title count, followed
+// by the disc, and then each title and its streams.
+func (w *Writer) WriteDiscInfo(info *DiscInfo) error {
+	if err := w.writeLine(TitleCountTag, strconv.Itoa(len(info.Titles))); err != nil {
+		return err
+	}
+	if err := w.writeGenericInfo(DiscInfoTag, &info.GenericInfo); err != nil {
+		return err
+	}
+	for t := range info.Titles {
+		title := &info.Titles[t]
+		if err := w.writeGenericInfo(TitleInfoTag, &title.GenericInfo, strconv.Itoa(t)); err != nil {
+			return err
+		}
+		for s := range title.Streams {
+			if err := w.writeGenericInfo(StreamInfoTag, &title.Streams[s].GenericInfo, strconv.Itoa(t), strconv.Itoa(s)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Write serializes any of the record types produced by
+// MakeMkvParser. TitleCount and the synthesized TitleComplete and
+// StreamAdded events are silently skipped, since WriteDiscInfo
+// produces everything needed to reconstruct them.
+func (w *Writer) Write(record any) error {
+	switch record := record.(type) {
+	case *Message:
+		return w.WriteMessage(record)
+	case *ProgressTitle:
+		return w.WriteProgressTitle(record)
+	case *ProgressUpdate:
+		return w.WriteProgressUpdate(record)
+	case *Drive:
+		return w.WriteDrive(record)
+	case *DiscInfo:
+		return w.WriteDiscInfo(record)
+	case *TitleCount, *TitleComplete, *StreamAdded:
+		return nil
+	default:
+		return fmt.Errorf("unable to write record of type %T", record)
+	}
+}
@@ -0,0 +1,91 @@
+package makemkv
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuoteRoundTrip(t *testing.T) {
+	tests := map[string]string{
+		"empty":           ``,
+		"plain":           `Volume Name`,
+		"embedded quote":  `The "Best" Disc`,
+		"comma":           `Title, The`,
+		"backslash quote": `a\"b`,
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			w := NewWriter(&b)
+			if err := w.WriteDrive(&Drive{DiscName: tt}); err != nil {
+				t.Fatal(err)
+			}
+			for result := range NewParser(&b).Stream() {
+				drive, ok := result.Parsed.(*Drive)
+				if !ok {
+					t.Fatalf("got %T, want *Drive", result.Parsed)
+				}
+				if drive.DiscName != tt {
+					t.Errorf("got %+q, want %+q", drive.DiscName, tt)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	parse := func(log string) []any {
+		result := []any{}
+		for msg := range NewParser(strings.NewReader(log)).Stream() {
+			switch msg.Parsed.(type) {
+			case nil, *TitleCount, *TitleComplete, *StreamAdded:
+				continue
+			}
+			result = append(result, msg.Parsed)
+		}
+		return result
+	}
+	want := parse(sampleLog)
+
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	for _, record := range want {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := parse(b.String())
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip mismatch:\n%s", b.String())
+	}
+}
+
+func TestWriteDiscInfo(t *testing.T) {
+	info := &DiscInfo{
+		GenericInfo: GenericInfo{
+			VolumeName: "SYNTHETIC",
+		},
+		Titles: []TitleInfo{
+			{
+				GenericInfo: GenericInfo{Duration: "1:00:00"},
+				Streams: []StreamInfo{
+					{GenericInfo: GenericInfo{Type: "Video"}},
+				},
+			},
+		},
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteDiscInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	want := `TCOUNT:1
+CINFO:32,0,"SYNTHETIC"
+TINFO:0,9,0,"1:00:00"
+SINFO:0,0,1,0,"Video"
+`
+	if b.String() != want {
+		t.Errorf("got %+q, want %+q", b.String(), want)
+	}
+}
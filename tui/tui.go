@@ -18,7 +18,15 @@ const (
 	maxWidth = 160
 )
 
-var helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render
+var (
+	helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render
+	// severityStyles color-codes messages from makemkvcon.
+	severityStyles = map[makemkv.Severity]func(...string) string{
+		makemkv.SeverityDebug:   lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render,
+		makemkv.SeverityWarning: lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Render,
+		makemkv.SeverityError:   lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true).Render,
+	}
+)
 
 type model struct {
 	progress progress.Model
@@ -26,6 +34,12 @@ type model struct {
 	current  string
 	logs     string
 	viewport viewport.Model
+	// titles is the number of titles makemkvcon reported on the
+	// disc, or 0 if it is not yet known.
+	titles int
+	// reports holds the quality of every title ripped so far, to
+	// be summarized once the run ends.
+	reports []*makemkv.QualityReport
 }
 
 type Eof struct {
@@ -98,7 +112,26 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case *makemkv.Message:
-			m.addLog(msg.Message)
+			text := msg.Message
+			if style, ok := severityStyles[makemkv.Classify(msg).Severity]; ok {
+				text = style(text)
+			}
+			m.addLog(text)
+			return m, nil
+
+		case *makemkv.TitleCount:
+			m.titles = msg.Count
+			m.addLog(fmt.Sprintf("[scan] found %d titles", msg.Count))
+			return m, nil
+
+		case *makemkv.TitleComplete:
+			m.addLog(fmt.Sprintf("[scan] title %d/%d: %s %s (%d streams)",
+				msg.Index+1, m.titles, msg.Title.SourceFileName, msg.Title.Duration, len(msg.Title.Streams)))
+			return m, nil
+
+		case *makemkv.QualityReport:
+			m.reports = append(m.reports, msg)
+			m.addLog("[quality] " + styleReport(msg))
 			return m, nil
 
 		case *makemkv.ProgressUpdate:
@@ -111,6 +144,12 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 	case Eof:
+		if len(m.reports) > 0 {
+			m.addLog("[summary] rip quality:")
+			for _, report := range m.reports {
+				m.addLog("[summary]   " + styleReport(report))
+			}
+		}
 		return m, tea.Sequence(finalPause(), tea.Quit)
 
 	// FrameMsg is sent when the progress bar wants to animate itself
@@ -124,6 +163,19 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+func styleReport(report *makemkv.QualityReport) string {
+	severity := makemkv.SeverityInfo
+	if report.Degraded {
+		severity = makemkv.SeverityError
+	} else if !report.Clean() {
+		severity = makemkv.SeverityWarning
+	}
+	if style, ok := severityStyles[severity]; ok {
+		return style(report.String())
+	}
+	return report.String()
+}
+
 func (m *model) addLog(log string) {
 	now := time.Now()
 	m.logs += now.Format(time.TimeOnly) + " | " + log + "\n"